@@ -0,0 +1,169 @@
+package billing
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/branila/restaurant-protocol/errors"
+	"github.com/branila/restaurant-protocol/inventory"
+	"github.com/branila/restaurant-protocol/models"
+	"github.com/branila/restaurant-protocol/money"
+)
+
+// AliquotaIVARistorazione è l'aliquota IVA applicata alla somministrazione di alimenti e bevande
+const AliquotaIVARistorazione = 10
+
+// Riga è una voce del conto
+type Riga struct {
+	Comanda        int    // -1 per le righe non legate a una comanda, come il coperto
+	Portata        string // "PRIMO", "SECONDO", "CONTORNO" o "COPERTO"
+	Posto          int    // posto al tavolo dell'ospite, 0 = non indicato
+	Descrizione    string
+	Quantita       int
+	PrezzoUnitario money.Importo // prezzo base della variante ordinata, IVA inclusa
+	Supplementi    money.Importo // sovrapprezzi delle aggiunte, per unità
+	Totale         money.Importo
+}
+
+// Subtotale raccoglie l'importo di una comanda
+type Subtotale struct {
+	Comanda int
+	Importo money.Importo
+}
+
+// Conto è il conto di un ordine. I prezzi del menu sono IVA inclusa, quindi l'IVA viene scorporata dal totale
+type Conto struct {
+	Tipo        string
+	Tavolo      int
+	Data        string
+	Righe       []Riga
+	Subtotali   []Subtotale   // uno per comanda, nell'ordine dell'ordine
+	Coperto     money.Importo // totale dei coperti
+	AliquotaIVA int
+	Imponibile  money.Importo
+	IVA         money.Importo
+	Totale      money.Importo
+}
+
+// Calcola il conto di un ordine con i prezzi del menu in vigore alla sua data e l'aliquota IVA indicata
+func Calcola(ordine models.Ordine, inv *inventory.Inventory, aliquotaIVA int) (Conto, error) {
+	giorno, err := time.Parse(inventory.FormatoData, ordine.Data)
+	if err != nil {
+		return Conto{}, errors.NewFormatoDataError(ordine.Data)
+	}
+	menu := inv.MenuAl(giorno)
+
+	conto := Conto{
+		Tipo:        ordine.TipoOrdine(),
+		Tavolo:      ordine.Tavolo,
+		Data:        ordine.Data,
+		AliquotaIVA: aliquotaIVA,
+	}
+
+	// Righe, subtotali e divisioni sono legati al numero della comanda, che quindi non può ripetersi
+	numeri := make(map[int]bool, len(ordine.Comande))
+	for _, comanda := range ordine.Comande {
+		if numeri[comanda.Numero] {
+			return Conto{}, errors.NewComandaDuplicataError(strconv.Itoa(comanda.Numero))
+		}
+		numeri[comanda.Numero] = true
+		subtotale := Subtotale{Comanda: comanda.Numero}
+
+		for _, portata := range portateComanda(comanda) {
+			riga, err := rigaPiatto(comanda.Numero, portata.nome, portata.piatto, menu)
+			if err != nil {
+				return Conto{}, err
+			}
+			conto.Righe = append(conto.Righe, riga)
+			subtotale.Importo += riga.Totale
+		}
+
+		conto.Subtotali = append(conto.Subtotali, subtotale)
+		conto.Totale += subtotale.Importo
+	}
+
+	if ordine.Coperti > 0 {
+		prezzo := menu.Coperto()
+		riga := Riga{
+			Comanda:        -1,
+			Portata:        "COPERTO",
+			Descrizione:    "Coperto",
+			Quantita:       ordine.Coperti,
+			PrezzoUnitario: prezzo,
+			Totale:         prezzo.Moltiplica(ordine.Coperti),
+		}
+		conto.Righe = append(conto.Righe, riga)
+		conto.Coperto = riga.Totale
+		conto.Totale += riga.Totale
+	}
+
+	conto.Imponibile, conto.IVA = conto.Totale.ScorporaIVA(aliquotaIVA)
+
+	return conto, nil
+}
+
+type portata struct {
+	nome   string
+	piatto *models.Piatto
+}
+
+// portateComanda elenca i piatti presenti nella comanda, nell'ordine del menu
+func portateComanda(comanda models.Comanda) []portata {
+	var portate []portata
+	for _, p := range []portata{
+		{inventory.PortataPrimo, comanda.Primo},
+		{inventory.PortataSecondo, comanda.Secondo},
+		{inventory.PortataContorno, comanda.Contorno},
+	} {
+		if p.piatto != nil {
+			portate = append(portate, p)
+		}
+	}
+	return portate
+}
+
+// rigaPiatto calcola la riga del conto di un piatto con i supplementi delle sue aggiunte
+func rigaPiatto(numeroComanda int, nomePortata string, piatto *models.Piatto, menu *inventory.Inventory) (Riga, error) {
+	voce, exists := menu.GetPiatto(piatto.Nome)
+	if !exists {
+		return Riga{}, errors.NewPiattoInesistenteError(piatto.Nome)
+	}
+
+	var supplementi money.Importo
+	var aggiunte []string
+	for _, mod := range piatto.Modifiche {
+		if mod.Tipo != "+" {
+			continue
+		}
+		if supplemento, exists := voce.Supplementi[mod.Voce]; exists {
+			supplementi += supplemento
+			aggiunte = append(aggiunte, fmt.Sprintf("+%s", mod.Voce))
+		}
+	}
+
+	prezzo, err := voce.PrezzoVariante(piatto.Variante)
+	if err != nil {
+		return Riga{}, err
+	}
+
+	descrizione := piatto.Nome
+	if piatto.Variante != "" {
+		descrizione = fmt.Sprintf("%s [%s]", descrizione, piatto.Variante)
+	}
+	if len(aggiunte) > 0 {
+		descrizione = fmt.Sprintf("%s (%s)", descrizione, strings.Join(aggiunte, ", "))
+	}
+
+	return Riga{
+		Comanda:        numeroComanda,
+		Portata:        nomePortata,
+		Posto:          piatto.Posto,
+		Descrizione:    descrizione,
+		Quantita:       1,
+		PrezzoUnitario: prezzo,
+		Supplementi:    supplementi,
+		Totale:         prezzo + supplementi,
+	}, nil
+}
@@ -0,0 +1,222 @@
+package billing
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/branila/restaurant-protocol/money"
+)
+
+// RiferimentoRiga identifica il piatto di una comanda nel conto
+type RiferimentoRiga struct {
+	Comanda int
+	Portata string
+}
+
+// Quota è il conto parziale pagato da un ospite
+type Quota struct {
+	Ospite      string
+	Righe       []Riga
+	AliquotaIVA int
+	Imponibile  money.Importo
+	IVA         money.Importo
+	Totale      money.Importo
+}
+
+// DividiPerComanda crea una quota per ogni comanda; il coperto viene diviso in parti uguali
+func DividiPerComanda(conto Conto) ([]Quota, error) {
+	ospiti := make([]string, 0, len(conto.Subtotali))
+	for _, subtotale := range conto.Subtotali {
+		ospiti = append(ospiti, nomeComanda(subtotale.Comanda))
+	}
+
+	return dividi(conto, ospiti, func(riga Riga) (string, bool) {
+		if riga.Comanda < 0 {
+			return "", false
+		}
+		return nomeComanda(riga.Comanda), true
+	})
+}
+
+// DividiInParti divide il conto in parti uguali ("alla romana"); i centesimi
+// che avanzano vanno alle prime quote, così la somma torna sempre con il totale
+func DividiInParti(conto Conto, parti int) ([]Quota, error) {
+	if parti <= 0 {
+		return nil, fmt.Errorf("il numero di parti deve essere positivo (%d)", parti)
+	}
+
+	importi := conto.Totale.Dividi(parti)
+	quote := make([]Quota, parti)
+	for i, importo := range importi {
+		quote[i] = nuovaQuota(fmt.Sprintf("Parte %d di %d", i+1, parti), conto.AliquotaIVA, []Riga{{
+			Comanda:        -1,
+			Portata:        "QUOTA",
+			Descrizione:    fmt.Sprintf("Quota del conto (1/%d)", parti),
+			Quantita:       1,
+			PrezzoUnitario: importo,
+			Totale:         importo,
+		}})
+	}
+
+	return quote, nil
+}
+
+// DividiPerAssegnazione assegna ogni piatto all'ospite indicato. I piatti non assegnati
+// e il coperto vengono divisi in parti uguali tra tutti gli ospiti
+func DividiPerAssegnazione(conto Conto, assegnazioni map[RiferimentoRiga]string) ([]Quota, error) {
+	if err := verificaRiferimenti(conto, len(assegnazioni), func(rif RiferimentoRiga) bool {
+		_, exists := assegnazioni[rif]
+		return exists
+	}); err != nil {
+		return nil, err
+	}
+
+	var ospiti []string
+	visti := make(map[string]bool)
+	for _, ospite := range assegnazioni {
+		if !visti[ospite] {
+			visti[ospite] = true
+			ospiti = append(ospiti, ospite)
+		}
+	}
+	sort.Strings(ospiti)
+
+	return dividi(conto, ospiti, func(riga Riga) (string, bool) {
+		ospite, exists := assegnazioni[RiferimentoRiga{riga.Comanda, riga.Portata}]
+		return ospite, exists
+	})
+}
+
+// DividiPerPosti assegna ogni piatto al posto indicato, con una quota per posto in ordine numerico.
+// Se posti è nil vengono usati i posti indicati sui piatti dell'ordine
+func DividiPerPosti(conto Conto, posti map[RiferimentoRiga]int) ([]Quota, error) {
+	if posti == nil {
+		posti = postiDelConto(conto)
+	}
+
+	numeri := make([]int, 0, len(posti))
+	visti := make(map[int]bool)
+	for rif, posto := range posti {
+		if posto <= 0 {
+			return nil, fmt.Errorf("posto non valido (%d) per la comanda %d, %s", posto, rif.Comanda, rif.Portata)
+		}
+		if !visti[posto] {
+			visti[posto] = true
+			numeri = append(numeri, posto)
+		}
+	}
+	sort.Ints(numeri)
+
+	if err := verificaRiferimenti(conto, len(posti), func(rif RiferimentoRiga) bool {
+		_, exists := posti[rif]
+		return exists
+	}); err != nil {
+		return nil, err
+	}
+
+	ospiti := make([]string, len(numeri))
+	for i, numero := range numeri {
+		ospiti[i] = nomePosto(numero)
+	}
+
+	return dividi(conto, ospiti, func(riga Riga) (string, bool) {
+		posto, exists := posti[RiferimentoRiga{riga.Comanda, riga.Portata}]
+		if !exists {
+			return "", false
+		}
+		return nomePosto(posto), true
+	})
+}
+
+// dividi assegna le righe agli ospiti con la funzione indicata; le righe non assegnate
+// vengono divise in parti uguali tra tutti. Se il coperto ha una persona per ospite,
+// ognuno paga il proprio
+func dividi(conto Conto, ospiti []string, assegna func(Riga) (string, bool)) ([]Quota, error) {
+	if len(ospiti) == 0 {
+		return nil, fmt.Errorf("nessun ospite a cui dividere il conto")
+	}
+
+	righe := make(map[string][]Riga, len(ospiti))
+	for _, riga := range conto.Righe {
+		if ospite, assegnata := assegna(riga); assegnata {
+			righe[ospite] = append(righe[ospite], riga)
+			continue
+		}
+
+		if riga.Portata == "COPERTO" && riga.Quantita == len(ospiti) {
+			for _, ospite := range ospiti {
+				righe[ospite] = append(righe[ospite], Riga{
+					Comanda:        riga.Comanda,
+					Portata:        riga.Portata,
+					Posto:          riga.Posto,
+					Descrizione:    riga.Descrizione,
+					Quantita:       1,
+					PrezzoUnitario: riga.PrezzoUnitario,
+					Totale:         riga.PrezzoUnitario,
+				})
+			}
+			continue
+		}
+
+		for i, importo := range riga.Totale.Dividi(len(ospiti)) {
+			righe[ospiti[i]] = append(righe[ospiti[i]], Riga{
+				Comanda:        riga.Comanda,
+				Portata:        riga.Portata,
+				Posto:          riga.Posto,
+				Descrizione:    fmt.Sprintf("%s (1/%d)", riga.Descrizione, len(ospiti)),
+				Quantita:       1,
+				PrezzoUnitario: importo,
+				Totale:         importo,
+			})
+		}
+	}
+
+	quote := make([]Quota, 0, len(ospiti))
+	for _, ospite := range ospiti {
+		quote = append(quote, nuovaQuota(ospite, conto.AliquotaIVA, righe[ospite]))
+	}
+
+	return quote, nil
+}
+
+// postiDelConto raccoglie i posti indicati sui piatti del conto
+func postiDelConto(conto Conto) map[RiferimentoRiga]int {
+	posti := make(map[RiferimentoRiga]int)
+	for _, riga := range conto.Righe {
+		if riga.Posto > 0 {
+			posti[RiferimentoRiga{riga.Comanda, riga.Portata}] = riga.Posto
+		}
+	}
+	return posti
+}
+
+// verificaRiferimenti controlla che ogni riferimento indicato corrisponda a un piatto del conto
+func verificaRiferimenti(conto Conto, riferimenti int, presente func(RiferimentoRiga) bool) error {
+	trovati := 0
+	for _, riga := range conto.Righe {
+		if riga.Comanda >= 0 && presente(RiferimentoRiga{riga.Comanda, riga.Portata}) {
+			trovati++
+		}
+	}
+	if trovati != riferimenti {
+		return fmt.Errorf("%d assegnazioni non corrispondono a nessun piatto del conto", riferimenti-trovati)
+	}
+	return nil
+}
+
+func nuovaQuota(ospite string, aliquotaIVA int, righe []Riga) Quota {
+	quota := Quota{Ospite: ospite, Righe: righe, AliquotaIVA: aliquotaIVA}
+	for _, riga := range righe {
+		quota.Totale += riga.Totale
+	}
+	quota.Imponibile, quota.IVA = quota.Totale.ScorporaIVA(aliquotaIVA)
+	return quota
+}
+
+func nomeComanda(numero int) string {
+	return fmt.Sprintf("Comanda %d", numero)
+}
+
+func nomePosto(numero int) string {
+	return fmt.Sprintf("Posto %d", numero)
+}
@@ -1,10 +1,14 @@
 package converter
 
 import (
+	"encoding/csv"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
+	"strconv"
+	"strings"
 
+	"github.com/branila/restaurant-protocol/inventory"
 	"github.com/branila/restaurant-protocol/models"
 	"gopkg.in/yaml.v2"
 )
@@ -47,3 +51,56 @@ func ToPrettyFormat(ordine models.Ordine, format string) (string, error) {
 		return "", fmt.Errorf("formato non supportato: %s", format)
 	}
 }
+
+// Converte le voci del menu in JSON
+func MenuToJSON(voci []inventory.VoceMenu) (string, error) {
+	if voci == nil {
+		voci = []inventory.VoceMenu{}
+	}
+	data, err := json.MarshalIndent(voci, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("errore nella conversione del menu in JSON: %w", err)
+	}
+	return string(data), nil
+}
+
+// Converte gli ordini ai fornitori in JSON
+func AcquistiToJSON(ordini []inventory.OrdineAcquisto) (string, error) {
+	if ordini == nil {
+		ordini = []inventory.OrdineAcquisto{}
+	}
+	data, err := json.MarshalIndent(ordini, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("errore nella conversione degli ordini ai fornitori in JSON: %w", err)
+	}
+	return string(data), nil
+}
+
+// Converte gli ordini ai fornitori in CSV, una riga per ingrediente
+func AcquistiToCSV(ordini []inventory.OrdineAcquisto) (string, error) {
+	var output strings.Builder
+	scrittore := csv.NewWriter(&output)
+
+	scrittore.Write([]string{"fornitore", "telefono", "email", "data", "ingrediente", "quantita", "unita", "scorta", "par"})
+	for _, ordine := range ordini {
+		for _, riga := range ordine.Righe {
+			scrittore.Write([]string{
+				ordine.Fornitore.Nome,
+				ordine.Fornitore.Telefono,
+				ordine.Fornitore.Email,
+				ordine.Data,
+				riga.Ingrediente,
+				strconv.FormatFloat(riga.Quantita, 'f', -1, 64),
+				riga.Unita,
+				strconv.FormatFloat(riga.Scorta, 'f', -1, 64),
+				strconv.FormatFloat(riga.Par, 'f', -1, 64),
+			})
+		}
+	}
+	scrittore.Flush()
+
+	if err := scrittore.Error(); err != nil {
+		return "", fmt.Errorf("errore nella conversione degli ordini ai fornitori in CSV: %w", err)
+	}
+	return output.String(), nil
+}
@@ -1,22 +1,34 @@
 package errors
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // Codici di errore
 const (
 	// Errori di validazione
-	ErrCodePiattiMultipli = 1001 // Tentativo di ordinare più piatti dello stesso tipo
-	ErrCodePiattoEsaurito = 1002 // Piatto non disponibile nell'inventario
-	ErrCodeComandaVuota   = 1003 // Comanda senza piatti
-	ErrCodeOrdineVuoto    = 1004 // Ordine senza comande
+	ErrCodePiattiMultipli   = 1001 // Tentativo di ordinare più piatti dello stesso tipo
+	ErrCodePiattoEsaurito   = 1002 // Piatto non disponibile nell'inventario
+	ErrCodeComandaVuota     = 1003 // Comanda senza piatti
+	ErrCodeOrdineVuoto      = 1004 // Ordine senza comande
+	ErrCodeDatiCliente      = 1005 // Dati del cliente mancanti o incoerenti con il tipo di ordine
+	ErrCodePortataErrata    = 1006 // Piatto ordinato in una portata a cui non appartiene
+	ErrCodePostoNonValido   = 1007 // Posto al tavolo oltre i coperti dichiarati
+	ErrCodeFuoriOrario      = 1008 // Piatto non servito nel giorno o nell'orario dell'ordine
+	ErrCodeComandaDuplicata = 1009 // Due comande con lo stesso numero nello stesso ordine
 
 	// Errori sintattici
 	ErrCodeSintassiGenerale = 2001 // Errore generico di sintassi
 	ErrCodeFormatoData      = 2002 // Formato data non valido
 	ErrCodeNumeroNonValido  = 2003 // Numero non valido per tavolo/comanda
+	ErrCodeFormatoOra       = 2004 // Orario non valido
 
 	// Errori relativi alle modifiche
 	ErrCodeModificaNonValida = 3001 // Modifica non consentita
+	ErrCodeOpzioneMancante   = 3002 // Opzione obbligatoria non specificata
+	ErrCodeOpzioneNonValida  = 3003 // Opzione o valore non previsto per il piatto
+	ErrCodeVarianteNonValida = 3004 // Variante (es. mezza porzione) non prevista per il piatto
 )
 
 // OrderError è un tipo di errore personalizzato che contiene informazioni dettagliate
@@ -41,10 +53,10 @@ func NewPiattiMultipliError(tipoPiatto, numeroComanda string) *OrderError {
 }
 
 // NewPiattoEsauritoError crea un errore per piatti non disponibili
-func NewPiattoEsauritoError(nomePiatto string, disponibilita int) *OrderError {
+func NewPiattoEsauritoError(nomePiatto string, disponibilita float64) *OrderError {
 	details := "Il piatto è esaurito per oggi"
 	if disponibilita > 0 {
-		details = fmt.Sprintf("Rimangono solo %d porzioni di questo piatto", disponibilita)
+		details = fmt.Sprintf("Rimangono solo %g porzioni di questo piatto", disponibilita)
 	}
 
 	return &OrderError{
@@ -54,6 +66,79 @@ func NewPiattoEsauritoError(nomePiatto string, disponibilita int) *OrderError {
 	}
 }
 
+// NewIngredienteEsauritoError crea un errore per piatti non disponibili a causa di un ingrediente
+func NewIngredienteEsauritoError(nomePiatto string, ingrediente string, dettaglio string) *OrderError {
+	return &OrderError{
+		Code:    ErrCodePiattoEsaurito,
+		Message: fmt.Sprintf("Il piatto '%s' non è disponibile: ingrediente '%s' esaurito", nomePiatto, ingrediente),
+		Details: dettaglio,
+	}
+}
+
+// NewPiattoSospesoError crea un errore per piatti tolti dal servizio anche se restano porzioni
+func NewPiattoSospesoError(nomePiatto string, motivo string) *OrderError {
+	details := "Il piatto è stato sospeso dalla cucina"
+	if motivo != "" {
+		details = fmt.Sprintf("Il piatto è stato sospeso dalla cucina: %s", motivo)
+	}
+
+	return &OrderError{
+		Code:    ErrCodePiattoEsaurito,
+		Message: fmt.Sprintf("Il piatto '%s' non è al momento disponibile", nomePiatto),
+		Details: details,
+	}
+}
+
+// NewFuoriOrarioError crea un errore per piatti non serviti nel giorno o nell'orario dell'ordine
+func NewFuoriOrarioError(nomePiatto string, quando string, disponibilita string) *OrderError {
+	return &OrderError{
+		Code:    ErrCodeFuoriOrario,
+		Message: fmt.Sprintf("Il piatto '%s' non viene servito %s", nomePiatto, quando),
+		Details: fmt.Sprintf("Il piatto è disponibile %s", disponibilita),
+	}
+}
+
+// NewOraMancanteError crea un errore per piatti serviti solo in certi orari ordinati senza indicare l'ora
+func NewOraMancanteError(nomePiatto string, disponibilita string) *OrderError {
+	return &OrderError{
+		Code:    ErrCodeFuoriOrario,
+		Message: fmt.Sprintf("Il piatto '%s' viene servito solo in certi orari, indicare l'ora dell'ordine (ORA HH:MM)", nomePiatto),
+		Details: fmt.Sprintf("Il piatto è disponibile %s", disponibilita),
+	}
+}
+
+// NewPiattoInesistenteError crea un errore per piatti non presenti nel menu
+func NewPiattoInesistenteError(nomePiatto string) *OrderError {
+	return &OrderError{
+		Code:    ErrCodePiattoEsaurito,
+		Message: fmt.Sprintf("Il piatto '%s' non esiste nel menu", nomePiatto),
+		Details: "Consultare il menu aggiornato per verificare i piatti disponibili",
+	}
+}
+
+// NewPortataErrataError crea un errore per piatti ordinati nella portata sbagliata
+func NewPortataErrataError(nomePiatto string, portata string, ammesse []string) *OrderError {
+	return &OrderError{
+		Code:    ErrCodePortataErrata,
+		Message: fmt.Sprintf("Il piatto '%s' non può essere ordinato come %s", nomePiatto, portata),
+		Details: fmt.Sprintf("Il piatto può essere ordinato solo come: %s", strings.Join(ammesse, ", ")),
+	}
+}
+
+// NewPostoNonValidoError crea un errore per posti al tavolo non compatibili con i coperti
+func NewPostoNonValidoError(posto int, coperti int) *OrderError {
+	details := "Indicare i coperti nell'intestazione (es. 'ORDINE 1 13/11/2025 COPERTI 4') per assegnare i piatti ai posti"
+	if coperti > 0 {
+		details = fmt.Sprintf("Il tavolo ha %d coperti: i posti vanno da @1 a @%d", coperti, coperti)
+	}
+
+	return &OrderError{
+		Code:    ErrCodePostoNonValido,
+		Message: fmt.Sprintf("Posto @%d non valido per questo ordine", posto),
+		Details: details,
+	}
+}
+
 // NewComandaVuotaError crea un errore per comande vuote
 func NewComandaVuotaError(numeroComanda string) *OrderError {
 	return &OrderError{
@@ -63,6 +148,15 @@ func NewComandaVuotaError(numeroComanda string) *OrderError {
 	}
 }
 
+// NewComandaDuplicataError crea un errore per due comande con lo stesso numero
+func NewComandaDuplicataError(numeroComanda string) *OrderError {
+	return &OrderError{
+		Code:    ErrCodeComandaDuplicata,
+		Message: fmt.Sprintf("La comanda %s compare più di una volta nell'ordine", numeroComanda),
+		Details: "Unire i piatti in una sola comanda o numerare le comande in modo diverso",
+	}
+}
+
 // NewOrdineVuotoError crea un errore per ordini vuoti
 func NewOrdineVuotoError() *OrderError {
 	return &OrderError{
@@ -90,6 +184,24 @@ func NewFormatoDataError(data string) *OrderError {
 	}
 }
 
+// NewFormatoOraError crea un errore di formato orario
+func NewFormatoOraError(ora string) *OrderError {
+	return &OrderError{
+		Code:    ErrCodeFormatoOra,
+		Message: fmt.Sprintf("Formato orario non valido: '%s'", ora),
+		Details: "Il formato corretto dell'orario è HH:MM",
+	}
+}
+
+// NewDatiClienteError crea un errore per dati del cliente mancanti o non previsti
+func NewDatiClienteError(tipoOrdine string, motivazione string) *OrderError {
+	return &OrderError{
+		Code:    ErrCodeDatiCliente,
+		Message: fmt.Sprintf("Dati non validi per un ordine di tipo %s", tipoOrdine),
+		Details: motivazione,
+	}
+}
+
 // NewNumeroNonValidoError crea un errore per numeri non validi
 func NewNumeroNonValidoError(contesto, valore string) *OrderError {
 	return &OrderError{
@@ -108,6 +220,43 @@ func NewModificaNonValidaError(piatto string, modifica string, motivazione strin
 	}
 }
 
+// NewOpzioneMancanteError crea un errore per opzioni obbligatorie non specificate
+func NewOpzioneMancanteError(piatto string, opzione string, valori []string) *OrderError {
+	details := fmt.Sprintf("Specificare l'opzione nel formato %s=\"<valore>\"", opzione)
+	if len(valori) > 0 {
+		details = fmt.Sprintf("Specificare %s=\"<valore>\" scegliendo tra: %s", opzione, strings.Join(valori, ", "))
+	}
+
+	return &OrderError{
+		Code:    ErrCodeOpzioneMancante,
+		Message: fmt.Sprintf("L'opzione '%s' è obbligatoria per il piatto '%s'", opzione, piatto),
+		Details: details,
+	}
+}
+
+// NewOpzioneNonValidaError crea un errore per opzioni o valori non previsti
+func NewOpzioneNonValidaError(piatto string, opzione string, motivazione string) *OrderError {
+	return &OrderError{
+		Code:    ErrCodeOpzioneNonValida,
+		Message: fmt.Sprintf("Opzione '%s' non valida per il piatto '%s'", opzione, piatto),
+		Details: motivazione,
+	}
+}
+
+// NewVarianteNonValidaError crea un errore per varianti non previste dal piatto
+func NewVarianteNonValidaError(piatto string, variante string, ammesse []string) *OrderError {
+	details := "Il piatto non prevede varianti, ordinarlo senza parentesi quadre"
+	if len(ammesse) > 0 {
+		details = fmt.Sprintf("Varianti disponibili: %s", strings.Join(ammesse, ", "))
+	}
+
+	return &OrderError{
+		Code:    ErrCodeVarianteNonValida,
+		Message: fmt.Sprintf("Variante '%s' non prevista per il piatto '%s'", variante, piatto),
+		Details: details,
+	}
+}
+
 // Is permette di confrontare i tipi di errore in base al codice
 func (e *OrderError) Is(target error) bool {
 	if t, ok := target.(*OrderError); ok {
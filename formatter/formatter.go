@@ -2,9 +2,12 @@ package formatter
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
+	"github.com/branila/restaurant-protocol/billing"
 	"github.com/branila/restaurant-protocol/models"
+	"github.com/branila/restaurant-protocol/money"
 )
 
 // Formatta un ordine in una stringa leggibile
@@ -12,7 +15,7 @@ func FormatOrdine(ordine models.Ordine) string {
 	var output strings.Builder
 
 	// Intestazione dell'ordine
-	output.WriteString(fmt.Sprintf("Ordine per il tavolo %d, data %s\n", ordine.Tavolo, ordine.Data))
+	output.WriteString(formatIntestazione(ordine))
 
 	// Formatta ogni comanda
 	for _, comanda := range ordine.Comande {
@@ -22,6 +25,103 @@ func FormatOrdine(ordine models.Ordine) string {
 	return output.String()
 }
 
+// Formatta l'intestazione in base al tipo di ordine
+func formatIntestazione(ordine models.Ordine) string {
+	switch ordine.TipoOrdine() {
+	case models.TipoAsporto:
+		return fmt.Sprintf("Ordine da asporto per %s, ritiro alle %s, data %s\n",
+			formatCliente(ordine.Cliente), ordine.Ora, ordine.Data)
+	case models.TipoDomicilio:
+		consegna := ""
+		if ordine.Ora != "" {
+			consegna = fmt.Sprintf(" alle %s", ordine.Ora)
+		}
+		indirizzo := "indirizzo sconosciuto"
+		if ordine.Cliente != nil {
+			indirizzo = ordine.Cliente.Indirizzo
+		}
+		return fmt.Sprintf("Ordine a domicilio per %s, consegna in %s%s, data %s\n",
+			formatCliente(ordine.Cliente), indirizzo, consegna, ordine.Data)
+	default:
+		data := ordine.Data
+		if ordine.Ora != "" {
+			data = fmt.Sprintf("%s ore %s", ordine.Data, ordine.Ora)
+		}
+		if ordine.Coperti > 0 {
+			return fmt.Sprintf("Ordine per il tavolo %d (%d coperti), data %s\n", ordine.Tavolo, ordine.Coperti, data)
+		}
+		return fmt.Sprintf("Ordine per il tavolo %d, data %s\n", ordine.Tavolo, data)
+	}
+}
+
+// Formatta nome e telefono del cliente
+func formatCliente(cliente *models.Cliente) string {
+	if cliente == nil {
+		return "cliente sconosciuto"
+	}
+	return fmt.Sprintf("%s (tel. %s)", cliente.Nome, cliente.Telefono)
+}
+
+// Formatta i piatti dell'ordine raggruppati per posto al tavolo, per chi serve in sala.
+// I piatti senza posto vengono elencati in fondo come piatti da condividere
+func FormatPerPosto(ordine models.Ordine) string {
+	return fmt.Sprintf("Piatti per posto, tavolo %d\n", ordine.Tavolo) + formatPiattiPerPosto(ordine)
+}
+
+// Formatta i piatti di ogni posto, in ordine di posto, seguiti da quelli da condividere
+func formatPiattiPerPosto(ordine models.Ordine) string {
+	var output strings.Builder
+	perPosto := make(map[int][]string)
+
+	for _, comanda := range ordine.Comande {
+		for _, p := range []struct {
+			categoria string
+			piatto    *models.Piatto
+		}{
+			{"Primo", comanda.Primo},
+			{"Secondo", comanda.Secondo},
+			{"Contorno", comanda.Contorno},
+		} {
+			if p.piatto == nil {
+				continue
+			}
+			perPosto[p.piatto.Posto] = append(perPosto[p.piatto.Posto], fmt.Sprintf("    Comanda %d, %s: %s%s %s\n",
+				comanda.Numero,
+				p.categoria,
+				formatNomePiatto(p.piatto),
+				formatOpzioni(p.piatto.Opzioni),
+				formatModifiche(p.piatto.Modifiche)))
+		}
+	}
+
+	for _, posto := range postiOrdinati(perPosto) {
+		output.WriteString(fmt.Sprintf("  Posto %d:\n", posto))
+		for _, riga := range perPosto[posto] {
+			output.WriteString(riga)
+		}
+	}
+	if len(perPosto[0]) > 0 {
+		output.WriteString("  Da condividere:\n")
+		for _, riga := range perPosto[0] {
+			output.WriteString(riga)
+		}
+	}
+
+	return output.String()
+}
+
+// Restituisce i posti indicati, in ordine crescente; il posto 0 (da condividere) è escluso
+func postiOrdinati[V any](perPosto map[int]V) []int {
+	posti := make([]int, 0, len(perPosto))
+	for posto := range perPosto {
+		if posto > 0 {
+			posti = append(posti, posto)
+		}
+	}
+	sort.Ints(posti)
+	return posti
+}
+
 // Formatta una comanda in una stringa leggibile
 func formatComanda(comanda models.Comanda) string {
 	var output strings.Builder
@@ -48,10 +148,47 @@ func formatComanda(comanda models.Comanda) string {
 
 // Formatta un piatto in una stringa leggibile
 func formatPiatto(categoria string, piatto *models.Piatto) string {
-	return fmt.Sprintf("    %s: %s %s\n",
+	if piatto.Posto > 0 {
+		categoria = fmt.Sprintf("%s [posto %d]", categoria, piatto.Posto)
+	}
+
+	return fmt.Sprintf("    %s: %s%s %s%s\n",
 		categoria,
-		piatto.Nome,
-		formatModifiche(piatto.Modifiche))
+		formatNomePiatto(piatto),
+		formatOpzioni(piatto.Opzioni),
+		formatModifiche(piatto.Modifiche),
+		formatTag(piatto.Tag))
+}
+
+// Formatta le indicazioni alimentari del piatto (es. "(vegano, senza glutine)")
+func formatTag(tag []string) string {
+	if len(tag) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (%s)", strings.Join(tag, ", "))
+}
+
+// Formatta il nome del piatto con la variante ordinata (es. "pasta al pomodoro [mezza]")
+func formatNomePiatto(piatto *models.Piatto) string {
+	if piatto.Variante == "" {
+		return piatto.Nome
+	}
+	return fmt.Sprintf("%s [%s]", piatto.Nome, piatto.Variante)
+}
+
+// Formatta le opzioni in maiuscolo per renderle ben visibili in cucina
+func formatOpzioni(opzioni []models.Opzione) string {
+	if len(opzioni) == 0 {
+		return ""
+	}
+
+	var result strings.Builder
+
+	for _, opz := range opzioni {
+		result.WriteString(fmt.Sprintf(" <%s: %s>", strings.ToUpper(opz.Nome), strings.ToUpper(opz.Valore)))
+	}
+
+	return result.String()
 }
 
 // Formatta le modifiche in una stringa leggibile
@@ -73,3 +210,116 @@ func formatModifiche(modifiche []models.Modifica) string {
 	result.WriteString("]")
 	return result.String()
 }
+
+// Formatta il conto di un ordine con righe, subtotali, coperto e IVA. Se i piatti hanno il posto
+// al tavolo le righe sono raggruppate per posto, altrimenti per comanda
+func FormatConto(conto billing.Conto) string {
+	var output strings.Builder
+
+	if conto.Tipo == models.TipoSala {
+		output.WriteString(fmt.Sprintf("Conto per il tavolo %d, data %s\n", conto.Tavolo, conto.Data))
+	} else {
+		output.WriteString(fmt.Sprintf("Conto, data %s\n", conto.Data))
+	}
+
+	if contoHaPosti(conto) {
+		output.WriteString(formatContoPerPosto(conto))
+	} else {
+		for _, subtotale := range conto.Subtotali {
+			output.WriteString(fmt.Sprintf("  Comanda %d:\n", subtotale.Comanda))
+			for _, riga := range conto.Righe {
+				if riga.Comanda == subtotale.Comanda {
+					output.WriteString(formatRigaConto("    ", riga.Descrizione, riga.Totale.String()))
+				}
+			}
+			output.WriteString(formatRigaConto("    ", "Subtotale", subtotale.Importo.String()))
+		}
+	}
+
+	for _, riga := range conto.Righe {
+		if riga.Comanda < 0 {
+			descrizione := fmt.Sprintf("%s x%d", riga.Descrizione, riga.Quantita)
+			output.WriteString(formatRigaConto("  ", descrizione, riga.Totale.String()))
+		}
+	}
+
+	output.WriteString(formatTotali(conto.Totale, conto.AliquotaIVA, conto.IVA, conto.Imponibile))
+
+	return output.String()
+}
+
+// Indica se almeno un piatto del conto ha il posto al tavolo
+func contoHaPosti(conto billing.Conto) bool {
+	for _, riga := range conto.Righe {
+		if riga.Posto > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Formatta le righe dei piatti raggruppate per posto, con il subtotale di ognuno;
+// i piatti senza posto seguono come piatti da condividere
+func formatContoPerPosto(conto billing.Conto) string {
+	var output strings.Builder
+	perPosto := make(map[int][]billing.Riga)
+	for _, riga := range conto.Righe {
+		if riga.Comanda >= 0 {
+			perPosto[riga.Posto] = append(perPosto[riga.Posto], riga)
+		}
+	}
+
+	gruppo := func(titolo string, righe []billing.Riga) {
+		output.WriteString(fmt.Sprintf("  %s:\n", titolo))
+		var subtotale money.Importo
+		for _, riga := range righe {
+			output.WriteString(formatRigaConto("    ", riga.Descrizione, riga.Totale.String()))
+			subtotale += riga.Totale
+		}
+		output.WriteString(formatRigaConto("    ", "Subtotale", subtotale.String()))
+	}
+	for _, posto := range postiOrdinati(perPosto) {
+		gruppo(fmt.Sprintf("Posto %d", posto), perPosto[posto])
+	}
+	if len(perPosto[0]) > 0 {
+		gruppo("Da condividere", perPosto[0])
+	}
+
+	return output.String()
+}
+
+// Formatta i conti separati, uno per ospite
+func FormatQuote(quote []billing.Quota) string {
+	var output strings.Builder
+
+	for i, quota := range quote {
+		if i > 0 {
+			output.WriteString("\n")
+		}
+		output.WriteString(fmt.Sprintf("Conto separato: %s\n", quota.Ospite))
+		for _, riga := range quota.Righe {
+			output.WriteString(formatRigaConto("    ", riga.Descrizione, riga.Totale.String()))
+		}
+		output.WriteString(formatTotali(quota.Totale, quota.AliquotaIVA, quota.IVA, quota.Imponibile))
+	}
+
+	return output.String()
+}
+
+// Formatta totale, IVA e imponibile in fondo a un conto
+func formatTotali(totale money.Importo, aliquotaIVA int, iva money.Importo, imponibile money.Importo) string {
+	var output strings.Builder
+
+	output.WriteString("  " + strings.Repeat("-", 48) + "\n")
+	output.WriteString(formatRigaConto("  ", "Totale", totale.String()))
+	output.WriteString(formatRigaConto("  ", fmt.Sprintf("di cui IVA %d%%", aliquotaIVA), iva.String()))
+	output.WriteString(formatRigaConto("  ", "Imponibile", imponibile.String()))
+
+	return output.String()
+}
+
+// Formatta una riga del conto con l'importo allineato a destra
+func formatRigaConto(rientro string, descrizione string, importo string) string {
+	larghezza := 48 - len(rientro) + 2
+	return fmt.Sprintf("%s%-*s%12s\n", rientro, larghezza-12, descrizione, importo)
+}
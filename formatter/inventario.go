@@ -0,0 +1,219 @@
+package formatter
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/branila/restaurant-protocol/inventory"
+	"github.com/branila/restaurant-protocol/units"
+)
+
+// Formatta il confronto tra inventario atteso e conteggio fisico, evidenziando le discrepanze
+func FormatRiconciliazione(riconciliazione inventory.Riconciliazione) string {
+	var output strings.Builder
+
+	if riconciliazione.Dal.IsZero() {
+		output.WriteString("Riconciliazione dell'inventario\n")
+	} else {
+		output.WriteString(fmt.Sprintf("Riconciliazione dell'inventario dal %s\n", riconciliazione.Dal.Format("02/01/2006 15:04")))
+	}
+
+	output.WriteString(fmt.Sprintf("  %-22s %10s %10s %10s %10s %10s %10s\n",
+		"Voce", "Iniziale", "Scaricato", "Caricato", "Atteso", "Contato", "Diff."))
+	for _, riga := range riconciliazione.Righe {
+		segno := ""
+		if riga.Differenza != 0 {
+			segno = " !"
+		}
+		output.WriteString(fmt.Sprintf("  %-22s %10g %10g %10g %10g %10g %+10g %s%s\n",
+			riga.Voce, riga.Iniziale, riga.Scaricato, riga.Caricato, riga.Atteso, riga.Contato, riga.Differenza, riga.Unita, segno))
+		if riga.Rettifiche > 0 {
+			output.WriteString(fmt.Sprintf("  %-22s rettifiche nel periodo: %d\n", "", riga.Rettifiche))
+		}
+	}
+
+	discrepanze := riconciliazione.Discrepanze()
+	if len(discrepanze) == 0 {
+		output.WriteString("Nessuna discrepanza: il conteggio coincide con l'inventario\n")
+		return output.String()
+	}
+
+	output.WriteString(fmt.Sprintf("Discrepanze: %d\n", len(discrepanze)))
+	for _, riga := range discrepanze {
+		output.WriteString(fmt.Sprintf("  %s: %+g %s", riga.Voce, riga.Differenza, riga.Unita))
+		if len(riga.Ordini) > 0 {
+			output.WriteString(fmt.Sprintf(" (ordini nel periodo: %s)", strings.Join(riga.Ordini, "; ")))
+		}
+		output.WriteString("\n")
+	}
+
+	return output.String()
+}
+
+// Formatta i movimenti dello storico, uno per riga, con l'ordine che li ha originati
+func FormatStorico(movimenti []inventory.Movimento) string {
+	var output strings.Builder
+
+	output.WriteString("Storico dell'inventario:\n")
+	if len(movimenti) == 0 {
+		output.WriteString("  nessun movimento\n")
+		return output.String()
+	}
+
+	for _, m := range movimenti {
+		voce := m.Piatto
+		if m.Ingrediente != "" {
+			voce = m.Ingrediente
+		}
+		if m.Lotto != "" {
+			voce = fmt.Sprintf("%s [lotto %s]", voce, m.Lotto)
+		}
+
+		var dettagli []string
+		if m.Ordine != "" {
+			dettagli = append(dettagli, fmt.Sprintf("%s, comanda %d", m.Ordine, m.Comanda))
+		}
+		if m.Operatore != "" {
+			dettagli = append(dettagli, "operatore "+m.Operatore)
+		}
+		if m.Motivo != "" {
+			dettagli = append(dettagli, m.Motivo)
+		}
+
+		riga := fmt.Sprintf("  %6d %s %-13s %-28s %10g", m.Seq, m.Timestamp.Format("02/01/2006 15:04:05"), m.Tipo, voce, m.Quantita)
+		if len(dettagli) > 0 {
+			riga += "  " + strings.Join(dettagli, " | ")
+		}
+		output.WriteString(riga + "\n")
+	}
+
+	return output.String()
+}
+
+// Formatta le previsioni di esaurimento dei piatti, segnalando quelli che non arrivano a fine servizio
+func FormatPrevisioni(previsioni []inventory.Previsione, opzioni inventory.OpzioniPrevisione) string {
+	var output strings.Builder
+
+	intestazione := fmt.Sprintf("Previsione di esaurimento alle %s", opzioni.Adesso.Format("15:04"))
+	if !opzioni.FineServizio.IsZero() {
+		intestazione += fmt.Sprintf(", fine del servizio alle %s", opzioni.FineServizio.Format("15:04"))
+	}
+	output.WriteString(intestazione + "\n")
+
+	output.WriteString(fmt.Sprintf("  %-22s %10s %10s %10s %10s  %s\n",
+		"Piatto", "Rimanenti", "Ritmo/h", "Recente", "Storico", "Esaurimento"))
+	for _, previsione := range previsioni {
+		output.WriteString(fmt.Sprintf("  %-22s %10g %10.1f %10s %10s  %s",
+			previsione.Piatto,
+			previsione.Rimanenti,
+			previsione.Ritmo,
+			formatRitmo(previsione.RitmoRecente),
+			formatRitmo(previsione.RitmoStorico),
+			formatEsaurimento(previsione, opzioni.Adesso)))
+
+		if !opzioni.FineServizio.IsZero() {
+			if previsione.Basta {
+				output.WriteString(" (basta)")
+			} else {
+				output.WriteString(fmt.Sprintf(" (NON BASTA: servono %g porzioni)", previsione.Fabbisogno))
+			}
+		}
+		output.WriteString("\n")
+	}
+
+	return output.String()
+}
+
+// Formatta un ritmo di consumo, o "-" se mancano i dati
+func formatRitmo(ritmo float64) string {
+	if ritmo < 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f", ritmo)
+}
+
+// Formatta l'istante previsto di esaurimento, con la data se non è oggi
+func formatEsaurimento(previsione inventory.Previsione, adesso time.Time) string {
+	switch {
+	case previsione.Rimanenti <= 0:
+		return "esaurito"
+	case previsione.Esaurimento.IsZero():
+		return "nessun consumo"
+	case previsione.Esaurimento.YearDay() != adesso.YearDay() || previsione.Esaurimento.Year() != adesso.Year():
+		return "il " + previsione.Esaurimento.Format("02/01 alle 15:04")
+	}
+	return "alle " + previsione.Esaurimento.Format("15:04")
+}
+
+// Formatta quante porzioni di ogni piatto si possono ancora servire e cosa le limita
+func FormatCapacita(capacita []inventory.Capacita) string {
+	var output strings.Builder
+
+	output.WriteString("Porzioni ancora servibili:\n")
+	for _, c := range capacita {
+		nome := c.Piatto
+		if c.Modifica != nil {
+			nome = fmt.Sprintf("  con %s%s", c.Modifica.Tipo, c.Modifica.Voce)
+		}
+
+		limite := "porzioni del piatto"
+		switch c.Limite {
+		case inventory.LimitePorzioni:
+		case inventory.LimiteSospeso:
+			limite = "piatto sospeso"
+		default:
+			limite = "scorta di " + c.Limite
+			if len(c.Contesa) > 0 {
+				limite += fmt.Sprintf(", condivisa con %s", strings.Join(c.Contesa, ", "))
+			}
+		}
+
+		output.WriteString(fmt.Sprintf("  %-26s %5d  (%s)\n", nome, c.Massimo, limite))
+	}
+
+	return output.String()
+}
+
+// Formatta gli ordini ai fornitori, uno per fornitore, pronti da inviare o da dettare al telefono
+func FormatAcquisti(ordini []inventory.OrdineAcquisto) string {
+	var output strings.Builder
+
+	if len(ordini) == 0 {
+		output.WriteString("Nessun ingrediente sotto il livello di riferimento\n")
+		return output.String()
+	}
+
+	for i, ordine := range ordini {
+		if i > 0 {
+			output.WriteString("\n")
+		}
+
+		if ordine.Fornitore.Nome == "" {
+			output.WriteString(fmt.Sprintf("Ingredienti senza fornitore, %s\n", ordine.Data))
+		} else {
+			output.WriteString(fmt.Sprintf("Ordine a %s, %s\n", ordine.Fornitore.Nome, ordine.Data))
+			var contatti []string
+			if ordine.Fornitore.Telefono != "" {
+				contatti = append(contatti, "tel. "+ordine.Fornitore.Telefono)
+			}
+			if ordine.Fornitore.Email != "" {
+				contatti = append(contatti, ordine.Fornitore.Email)
+			}
+			if len(contatti) > 0 {
+				output.WriteString(fmt.Sprintf("  %s\n", strings.Join(contatti, ", ")))
+			}
+		}
+
+		for _, riga := range ordine.Righe {
+			unita := units.Unita(riga.Unita)
+			output.WriteString(fmt.Sprintf("  %-22s %14s  (scorta %s, par %s)\n",
+				riga.Ingrediente,
+				units.Quantita{Valore: riga.Quantita, Unita: unita}.Leggibile(),
+				units.Quantita{Valore: riga.Scorta, Unita: unita}.Leggibile(),
+				units.Quantita{Valore: riga.Par, Unita: unita}.Leggibile()))
+		}
+	}
+
+	return output.String()
+}
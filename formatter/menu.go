@@ -0,0 +1,183 @@
+package formatter
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/branila/restaurant-protocol/inventory"
+)
+
+// Formatta il menu come testo, con le porzioni rimaste per ogni piatto
+func FormatMenu(voci []inventory.VoceMenu) string {
+	var output strings.Builder
+
+	output.WriteString("Menu:\n")
+	if len(voci) == 0 {
+		output.WriteString("  nessun piatto corrisponde ai criteri indicati\n")
+		return output.String()
+	}
+
+	for _, voce := range voci {
+		output.WriteString(fmt.Sprintf("  %-9s %-30s %10s  %s\n",
+			nomePortata(voce.Portata),
+			voce.Nome,
+			voce.Prezzo.String(),
+			formatRimanenti(voce)))
+
+		var dettagli []string
+		if varianti := formatVarianti(voce); varianti != "" {
+			dettagli = append(dettagli, "varianti: "+varianti)
+		}
+		if len(voce.Allergeni) > 0 {
+			dettagli = append(dettagli, "allergeni: "+strings.Join(voce.Allergeni, ", "))
+		}
+		if indicazioni := formatIndicazioni(voce); indicazioni != "" {
+			dettagli = append(dettagli, indicazioni)
+		}
+		if voce.Orari != "" {
+			dettagli = append(dettagli, "servito "+voce.Orari)
+		}
+		if len(dettagli) > 0 {
+			output.WriteString(fmt.Sprintf("  %-9s %s\n", "", strings.Join(dettagli, " | ")))
+		}
+	}
+
+	return output.String()
+}
+
+// Formatta il menu come tabella Markdown
+func FormatMenuMarkdown(voci []inventory.VoceMenu) string {
+	var output strings.Builder
+
+	output.WriteString("| Portata | Piatto | Prezzo | Rimanenti | Varianti | Allergeni | Indicazioni |\n")
+	output.WriteString("|---|---|---:|---|---|---|---|\n")
+
+	for _, voce := range voci {
+		indicazioni := formatIndicazioni(voce)
+		if voce.Orari != "" {
+			indicazioni = strings.TrimPrefix(indicazioni+", servito "+voce.Orari, ", ")
+		}
+		output.WriteString(fmt.Sprintf("| %s | %s | %s | %s | %s | %s | %s |\n",
+			nomePortata(voce.Portata),
+			escapeMarkdown(voce.Nome),
+			voce.Prezzo.String(),
+			formatRimanenti(voce),
+			formatVarianti(voce),
+			strings.Join(voce.Allergeni, ", "),
+			indicazioni))
+	}
+
+	return output.String()
+}
+
+// Elenca le varianti con il loro prezzo (es. "mezza 5,10 €")
+func formatVarianti(voce inventory.VoceMenu) string {
+	varianti := make([]string, len(voce.Varianti))
+	for i, variante := range voce.Varianti {
+		varianti[i] = fmt.Sprintf("%s %s", variante.Nome, variante.Prezzo)
+	}
+	return strings.Join(varianti, ", ")
+}
+
+// Elenca i tag della voce, seguiti da quelli ottenibili su richiesta (es. "vegano senza burro")
+func formatIndicazioni(voce inventory.VoceMenu) string {
+	indicazioni := slices.Clone(voce.Tag)
+	for _, tag := range slices.Sorted(maps.Keys(voce.SuRichiesta)) {
+		indicazioni = append(indicazioni, fmt.Sprintf("%s senza %s", tag, strings.Join(voce.SuRichiesta[tag], " e ")))
+	}
+	return strings.Join(indicazioni, ", ")
+}
+
+// Descrive le porzioni rimaste, segnalando i piatti non ordinabili
+func formatRimanenti(voce inventory.VoceMenu) string {
+	if voce.Disponibile {
+		return fmt.Sprintf("%g porzioni", voce.Rimanenti)
+	}
+	if voce.Rimanenti > 0 {
+		return fmt.Sprintf("%g porzioni (non disponibile)", voce.Rimanenti)
+	}
+	return "esaurito"
+}
+
+// Restituisce il nome della portata come compare nelle comande (es. "Primo")
+func nomePortata(portata string) string {
+	if portata == "" {
+		return ""
+	}
+	return portata[:1] + strings.ToLower(portata[1:])
+}
+
+func escapeMarkdown(testo string) string {
+	return strings.ReplaceAll(testo, "|", "\\|")
+}
+
+// Formatta l'elenco delle versioni del menu, indicando quella in vigore
+func FormatVersioni(versioni []inventory.VersioneMenu, inVigore string) string {
+	var output strings.Builder
+
+	output.WriteString("Versioni del menu:\n")
+	if len(versioni) == 0 {
+		output.WriteString("  nessuna versione registrata, vale il menu corrente\n")
+		return output.String()
+	}
+
+	for _, versione := range versioni {
+		segno := " "
+		if versione.Nome == inVigore {
+			segno = "*"
+		}
+		output.WriteString(fmt.Sprintf("%s %-20s dal %s  %d piatti\n",
+			segno, versione.Nome, versione.Dal.Format(inventory.FormatoData), len(versione.Piatti)))
+	}
+
+	return output.String()
+}
+
+// Formatta le differenze tra due versioni del menu
+func FormatDiffMenu(diff inventory.DiffMenu) string {
+	var output strings.Builder
+
+	output.WriteString(fmt.Sprintf("Differenze tra '%s' e '%s':\n", diff.Da, diff.A))
+	if diff.Vuoto() {
+		output.WriteString("  nessuna differenza\n")
+		return output.String()
+	}
+
+	for _, nome := range diff.Nuovi {
+		output.WriteString(fmt.Sprintf("  + %s\n", nome))
+	}
+	for _, nome := range diff.Rimossi {
+		output.WriteString(fmt.Sprintf("  - %s\n", nome))
+	}
+	for _, piatto := range diff.Modificati {
+		output.WriteString(fmt.Sprintf("  ~ %s\n", piatto.Piatto))
+		if piatto.PortateDa != nil || piatto.PortateA != nil {
+			output.WriteString(fmt.Sprintf("      portate: %s -> %s\n", elencoPortate(piatto.PortateDa), elencoPortate(piatto.PortateA)))
+		}
+		if piatto.PrezzoDa != piatto.PrezzoA {
+			output.WriteString(fmt.Sprintf("      prezzo: %s -> %s\n", piatto.PrezzoDa, piatto.PrezzoA))
+		}
+		for _, modifica := range piatto.ModificheNuove {
+			output.WriteString(fmt.Sprintf("      ora consentita: %s%s\n", modifica.Tipo, modifica.Voce))
+		}
+		for _, modifica := range piatto.ModificheTolte {
+			output.WriteString(fmt.Sprintf("      non più consentita: %s%s\n", modifica.Tipo, modifica.Voce))
+		}
+	}
+
+	return output.String()
+}
+
+// Elenca le portate come compaiono nelle comande; nessuna portata indica un piatto ordinabile ovunque
+func elencoPortate(portate []string) string {
+	if len(portate) == 0 {
+		return "qualsiasi"
+	}
+	nomi := make([]string, len(portate))
+	for i, portata := range portate {
+		nomi[i] = nomePortata(portata)
+	}
+	return strings.Join(nomi, ", ")
+}
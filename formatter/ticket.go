@@ -0,0 +1,25 @@
+package formatter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/branila/restaurant-protocol/routing"
+)
+
+// Formatta il ticket di una stazione: l'intestazione della stazione seguita dai suoi piatti,
+// raggruppati per posto al tavolo se l'ordine li indica
+func FormatTicket(ticket routing.Ticket) string {
+	var output strings.Builder
+
+	output.WriteString(fmt.Sprintf("=== Stazione %s ===\n", ticket.Stazione))
+	if !ticket.Ordine.HaPosti() {
+		output.WriteString(FormatOrdine(ticket.Ordine))
+		return output.String()
+	}
+
+	output.WriteString(formatIntestazione(ticket.Ordine))
+	output.WriteString(formatPiattiPerPosto(ticket.Ordine))
+
+	return output.String()
+}
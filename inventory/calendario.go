@@ -0,0 +1,238 @@
+package inventory
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/branila/restaurant-protocol/errors"
+)
+
+// Formati di data e ora usati dal protocollo
+const (
+	FormatoData = "02/01/2006"
+	FormatoOra  = "15:04"
+)
+
+// Calendario limita i giorni e gli orari in cui un piatto viene servito.
+// I campi vuoti non pongono limiti
+type Calendario struct {
+	Giorni  []time.Weekday
+	Fasce   []Fascia
+	Periodi []Periodo // per i piatti speciali, es. il menu di Natale
+}
+
+// Fascia è un intervallo orario in minuti dalla mezzanotte; se Da > A attraversa la mezzanotte
+type Fascia struct {
+	Da int
+	A  int
+}
+
+// Periodo è un intervallo di date, estremi inclusi
+type Periodo struct {
+	Dal time.Time
+	Al  time.Time
+}
+
+var nomiGiorni = map[time.Weekday]string{
+	time.Monday:    "lunedì",
+	time.Tuesday:   "martedì",
+	time.Wednesday: "mercoledì",
+	time.Thursday:  "giovedì",
+	time.Friday:    "venerdì",
+	time.Saturday:  "sabato",
+	time.Sunday:    "domenica",
+}
+
+// ParseGiorno riconosce il nome italiano di un giorno della settimana, con o senza accento
+func ParseGiorno(nome string) (time.Weekday, error) {
+	nome = strings.ToLower(strings.TrimSpace(nome))
+	for giorno, italiano := range nomiGiorni {
+		if nome == italiano || nome == strings.ReplaceAll(italiano, "ì", "i") {
+			return giorno, nil
+		}
+	}
+	return 0, fmt.Errorf("giorno %q non valido, usare lunedì, martedì, ... domenica", nome)
+}
+
+// ParseFascia legge un intervallo orario nel formato "12:00-15:00"
+func ParseFascia(testo string) (Fascia, error) {
+	da, a, trovato := strings.Cut(testo, "-")
+	if !trovato {
+		return Fascia{}, fmt.Errorf("fascia oraria %q non valida, usare il formato 12:00-15:00", testo)
+	}
+
+	inizio, err := time.Parse(FormatoOra, strings.TrimSpace(da))
+	if err != nil {
+		return Fascia{}, fmt.Errorf("fascia oraria %q non valida, usare il formato 12:00-15:00", testo)
+	}
+	fine, err := time.Parse(FormatoOra, strings.TrimSpace(a))
+	if err != nil {
+		return Fascia{}, fmt.Errorf("fascia oraria %q non valida, usare il formato 12:00-15:00", testo)
+	}
+
+	return Fascia{Da: minuti(inizio), A: minuti(fine)}, nil
+}
+
+func (f Fascia) contiene(minuto int) bool {
+	if f.Da <= f.A {
+		return minuto >= f.Da && minuto < f.A
+	}
+	return minuto >= f.Da || minuto < f.A
+}
+
+func (f Fascia) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", f.Da/60, f.Da%60, f.A/60, f.A%60)
+}
+
+// contiene confronta solo le date: un giorno con un orario o in un altro fuso (es. time.Now())
+// rientra nel periodo anche nell'ultimo giorno
+func (p Periodo) contiene(giorno time.Time) bool {
+	giorno = soloData(giorno)
+	return !giorno.Before(soloData(p.Dal)) && !giorno.After(soloData(p.Al))
+}
+
+func (p Periodo) String() string {
+	return fmt.Sprintf("dal %s al %s", p.Dal.Format(FormatoData), p.Al.Format(FormatoData))
+}
+
+// Descrizione spiega in italiano quando il piatto è disponibile
+func (c Calendario) Descrizione() string {
+	var parti []string
+
+	if len(c.Giorni) > 0 {
+		giorni := make([]string, len(c.Giorni))
+		for i, giorno := range c.Giorni {
+			giorni[i] = nomiGiorni[giorno]
+		}
+		parti = append(parti, "il "+strings.Join(giorni, ", "))
+	}
+	if len(c.Fasce) > 0 {
+		fasce := make([]string, len(c.Fasce))
+		for i, fascia := range c.Fasce {
+			fasce[i] = fascia.String()
+		}
+		parti = append(parti, "negli orari "+strings.Join(fasce, ", "))
+	}
+	if len(c.Periodi) > 0 {
+		periodi := make([]string, len(c.Periodi))
+		for i, periodo := range c.Periodi {
+			periodi[i] = periodo.String()
+		}
+		parti = append(parti, strings.Join(periodi, ", "))
+	}
+
+	if len(parti) == 0 {
+		return "sempre"
+	}
+	return strings.Join(parti, "; ")
+}
+
+// verificaOrdine controlla il calendario per un ordine, che deve indicare l'ora se il piatto
+// è servito solo in certe fasce orarie
+func (c Calendario) verificaOrdine(nomePiatto string, giorno time.Time, ora string) error {
+	if ora == "" && len(c.Fasce) > 0 {
+		return errors.NewOraMancanteError(nomePiatto, c.Descrizione())
+	}
+	return c.verifica(nomePiatto, giorno, ora)
+}
+
+// verifica controlla che il piatto sia servito nel giorno indicato e, se ora non è vuota, in quell'orario.
+// Senza ora basta che il piatto sia servito in qualche momento del giorno, come per il menu di una data
+func (c Calendario) verifica(nomePiatto string, giorno time.Time, ora string) error {
+	fuoriOrario := func(quando string) error {
+		return errors.NewFuoriOrarioError(nomePiatto, quando, c.Descrizione())
+	}
+
+	if len(c.Periodi) > 0 {
+		incluso := false
+		for _, periodo := range c.Periodi {
+			if periodo.contiene(giorno) {
+				incluso = true
+				break
+			}
+		}
+		if !incluso {
+			return fuoriOrario(fmt.Sprintf("il %s", giorno.Format(FormatoData)))
+		}
+	}
+
+	if len(c.Giorni) > 0 {
+		incluso := false
+		for _, g := range c.Giorni {
+			if g == giorno.Weekday() {
+				incluso = true
+				break
+			}
+		}
+		if !incluso {
+			return fuoriOrario(fmt.Sprintf("di %s", nomiGiorni[giorno.Weekday()]))
+		}
+	}
+
+	if len(c.Fasce) > 0 && ora != "" {
+		orario, err := time.Parse(FormatoOra, ora)
+		if err != nil {
+			return errors.NewFormatoOraError(ora)
+		}
+		incluso := false
+		for _, fascia := range c.Fasce {
+			if fascia.contiene(minuti(orario)) {
+				incluso = true
+				break
+			}
+		}
+		if !incluso {
+			return fuoriOrario(fmt.Sprintf("alle %s", ora))
+		}
+	}
+
+	return nil
+}
+
+// VerificaDisponibilitaAl controlla, oltre alle porzioni rimaste e agli ingredienti non scaduti
+// nel giorno dell'ordine, che il piatto sia servito in quel giorno e nell'ora dell'ordine; l'ora
+// è obbligatoria per i piatti serviti solo in certe fasce orarie
+func (inv *Inventory) VerificaDisponibilitaAl(nome string, giorno time.Time, ora string) error {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	if err := inv.verificaDisponibilita(nome, giorno); err != nil {
+		return err
+	}
+
+	piatto := inv.piatti[nome]
+	if piatto.Calendario == nil {
+		return nil
+	}
+	return piatto.Calendario.verificaOrdine(nome, giorno, ora)
+}
+
+// VerificaCalendario controlla solo che il piatto sia servito nel giorno e nell'ora dell'ordine,
+// senza guardare porzioni e scorte. L'ora è obbligatoria per i piatti con fasce orarie
+func (inv *Inventory) VerificaCalendario(nome string, giorno time.Time, ora string) error {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	piatto, exists := inv.piatti[nome]
+	if !exists {
+		return errors.NewPiattoInesistenteError(nome)
+	}
+	if piatto.Calendario == nil {
+		return nil
+	}
+	return piatto.Calendario.verificaOrdine(nome, giorno, ora)
+}
+
+// VerificaScorteAl controlla solo porzioni e ingredienti non scaduti nel giorno indicato,
+// senza il calendario, che va verificato sul menu in vigore in quel giorno
+func (inv *Inventory) VerificaScorteAl(nome string, giorno time.Time) error {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	return inv.verificaDisponibilita(nome, giorno)
+}
+
+func minuti(t time.Time) int {
+	return t.Hour()*60 + t.Minute()
+}
@@ -0,0 +1,172 @@
+package inventory
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/branila/restaurant-protocol/models"
+)
+
+// Limiti della capacità diversi da un ingrediente
+const (
+	LimitePorzioni = "porzioni"
+	LimiteSospeso  = "sospeso"
+)
+
+// Impegno è una quantità di un piatto che si prevede di servire: la capacità viene
+// calcolata su ciò che resta dopo averla sottratta
+type Impegno struct {
+	Piatto    string
+	Modifiche []models.Modifica
+	Porzioni  int
+}
+
+// Capacita è il numero massimo di porzioni di un piatto che si possono ancora servire,
+// se da qui in avanti si servisse solo quello
+type Capacita struct {
+	Piatto   string
+	Modifica *models.Modifica // nil per il piatto senza modifiche
+	Massimo  int
+	Limite   string   // LimitePorzioni, LimiteSospeso o l'ingrediente che finisce per primo
+	Contesa  []string // gli altri piatti che usano l'ingrediente limite
+}
+
+// Capacita calcola quante porzioni di ogni piatto si possono ancora servire nel giorno indicato,
+// sia con la ricetta base sia con ciascuna delle modifiche consentite che cambiano gli ingredienti.
+// Gli impegni vengono sottratti prima, così si può chiedere ad esempio quante bistecche restano
+// dopo aver servito dieci porzioni di pasta che usano gli stessi ingredienti
+func (inv *Inventory) Capacita(impegni []Impegno, giorno time.Time) ([]Capacita, error) {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	porzioni := make(map[string]float64, len(inv.piatti))
+	for nome, piatto := range inv.piatti {
+		porzioni[nome] = piatto.Disponibilita
+	}
+	scorte := make(map[string]float64, len(inv.ingredienti))
+	for nome, ingrediente := range inv.ingredienti {
+		scorte[nome] = ingrediente.DisponibileAl(giorno)
+	}
+
+	for i, impegno := range impegni {
+		if err := inv.impegna(impegno, porzioni, scorte); err != nil {
+			return nil, fmt.Errorf("impegni[%d]: %w", i, err)
+		}
+	}
+
+	var capacita []Capacita
+	for _, nome := range ordinaChiavi(inv.piatti) {
+		piatto := inv.piatti[nome]
+		capacita = append(capacita, inv.capacita(piatto, nil, porzioni[nome], scorte))
+
+		for _, voce := range ordinaChiavi(piatto.ModificheConsentite) {
+			modifica := models.Modifica{Tipo: "-", Voce: voce}
+			if piatto.ModificheConsentite[voce] {
+				modifica.Tipo = "+"
+			}
+			if !cambiaRicetta(piatto, modifica) {
+				continue
+			}
+			capacita = append(capacita, inv.capacita(piatto, &modifica, porzioni[nome], scorte))
+		}
+	}
+
+	return capacita, nil
+}
+
+// impegna sottrae l'impegno dalle porzioni e dalle scorte. Va chiamata con il lock già acquisito
+func (inv *Inventory) impegna(impegno Impegno, porzioni, scorte map[string]float64) error {
+	piatto, exists := inv.piatti[impegno.Piatto]
+	if !exists {
+		return fmt.Errorf("il piatto '%s' non esiste nel menu", impegno.Piatto)
+	}
+	if impegno.Porzioni <= 0 {
+		return fmt.Errorf("le porzioni impegnate di '%s' devono essere positive (%d)", impegno.Piatto, impegno.Porzioni)
+	}
+	for _, modifica := range impegno.Modifiche {
+		if consentita, exists := piatto.ModificheConsentite[modifica.Voce]; !exists || consentita != (modifica.Tipo == "+") {
+			return fmt.Errorf("la modifica %s%s non è consentita per '%s'", modifica.Tipo, modifica.Voce, impegno.Piatto)
+		}
+	}
+
+	quantita := float64(impegno.Porzioni)
+	if porzioni[piatto.Nome] < quantita {
+		return fmt.Errorf("'%s' ha solo %g porzioni, ne sono impegnate %d", piatto.Nome, porzioni[piatto.Nome], impegno.Porzioni)
+	}
+	porzioni[piatto.Nome] = arrotondaPorzioni(porzioni[piatto.Nome] - quantita)
+
+	dosi := inv.fabbisogno(piatto, impegno.Modifiche)
+	for _, ingrediente := range ordinaChiavi(dosi) {
+		necessario := dosi[ingrediente] * quantita
+		if scorte[ingrediente] < necessario {
+			return fmt.Errorf("gli impegni richiedono %g di %s, ne restano %g", necessario, ingrediente, scorte[ingrediente])
+		}
+		scorte[ingrediente] -= necessario
+	}
+	return nil
+}
+
+// capacita calcola il massimo servibile di un piatto con una modifica, date le porzioni e
+// le scorte rimaste. Va chiamata con il lock già acquisito
+func (inv *Inventory) capacita(piatto Piatto, modifica *models.Modifica, porzioni float64, scorte map[string]float64) Capacita {
+	capacita := Capacita{Piatto: piatto.Nome, Modifica: modifica}
+	if piatto.Sospeso {
+		capacita.Limite = LimiteSospeso
+		return capacita
+	}
+
+	massimo := porzioni
+	capacita.Limite = LimitePorzioni
+
+	var modifiche []models.Modifica
+	if modifica != nil {
+		modifiche = []models.Modifica{*modifica}
+	}
+	dosi := inv.fabbisogno(piatto, modifiche)
+	for _, ingrediente := range ordinaChiavi(dosi) {
+		if servibili := scorte[ingrediente] / dosi[ingrediente]; servibili < massimo {
+			massimo = servibili
+			capacita.Limite = ingrediente
+		}
+	}
+	capacita.Massimo = int(math.Floor(arrotondaPorzioni(max(massimo, 0))))
+
+	if capacita.Limite != LimitePorzioni {
+		for _, nome := range ordinaChiavi(inv.piatti) {
+			altro := inv.piatti[nome]
+			if nome == piatto.Nome {
+				continue
+			}
+			if inv.usaScorta(altro, capacita.Limite) {
+				capacita.Contesa = append(capacita.Contesa, nome)
+			}
+		}
+	}
+
+	return capacita
+}
+
+// cambiaRicetta indica se la modifica cambia gli ingredienti consumati dal piatto
+func cambiaRicetta(piatto Piatto, modifica models.Modifica) bool {
+	if modifica.Tipo == "+" {
+		_, exists := piatto.Aggiunte[modifica.Voce]
+		return exists
+	}
+	_, exists := piatto.Ricetta[modifica.Voce]
+	return exists
+}
+
+// MassimoPiatto restituisce la capacità del piatto con la ricetta base, dopo gli impegni indicati
+func (inv *Inventory) MassimoPiatto(nomePiatto string, impegni []Impegno, giorno time.Time) (Capacita, error) {
+	capacita, err := inv.Capacita(impegni, giorno)
+	if err != nil {
+		return Capacita{}, err
+	}
+	i := slices.IndexFunc(capacita, func(c Capacita) bool { return c.Piatto == nomePiatto && c.Modifica == nil })
+	if i < 0 {
+		return Capacita{}, fmt.Errorf("il piatto '%s' non esiste nel menu", nomePiatto)
+	}
+	return capacita[i], nil
+}
@@ -0,0 +1,469 @@
+package inventory
+
+import (
+	"bytes"
+	"encoding/csv"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/branila/restaurant-protocol/money"
+)
+
+// Colonne del file CSV dell'inventario
+const (
+	ColonnaPiatto    = "piatto"
+	ColonnaPortata   = "portata"
+	ColonnaPorzioni  = "porzioni"
+	ColonnaModifiche = "modifiche"
+	ColonnaPrezzo    = "prezzo"
+)
+
+var colonneCSV = []string{ColonnaPiatto, ColonnaPortata, ColonnaPorzioni, ColonnaModifiche, ColonnaPrezzo}
+
+// ErroreRiga è un errore di validazione di una riga del file CSV
+type ErroreRiga struct {
+	Riga      int    // numero di riga nel file, a partire da 1
+	Colonna   string // vuota se l'errore riguarda l'intera riga
+	Messaggio string
+}
+
+func (e ErroreRiga) Error() string {
+	if e.Colonna == "" {
+		return fmt.Sprintf("riga %d: %s", e.Riga, e.Messaggio)
+	}
+	return fmt.Sprintf("riga %d, colonna %s: %s", e.Riga, e.Colonna, e.Messaggio)
+}
+
+// ErroriCSV raccoglie gli errori di tutte le righe non valide, così il file si corregge in una volta sola
+type ErroriCSV []ErroreRiga
+
+func (e ErroriCSV) Error() string {
+	righe := make([]string, len(e))
+	for i, errore := range e {
+		righe[i] = "  " + errore.Error()
+	}
+	return fmt.Sprintf("il file CSV contiene errori:\n%s", strings.Join(righe, "\n"))
+}
+
+// ordinati restituisce gli errori in ordine di riga
+func (e ErroriCSV) ordinati() ErroriCSV {
+	sort.SliceStable(e, func(i, j int) bool { return e[i].Riga < e[j].Riga })
+	return e
+}
+
+// ImportazioneCSV riassume le modifiche apportate dall'importazione in unione
+type ImportazioneCSV struct {
+	Aggiunti   []string // piatti nuovi
+	Aggiornati []string // piatti già presenti, con le porzioni o gli altri campi cambiati
+	Invariati  []string // piatti già presenti e identici al file
+}
+
+// rigaCSV è una riga valida del file; i campi lasciati vuoti restano a nil
+type rigaCSV struct {
+	riga      int
+	nome      string
+	portata   string
+	porzioni  *float64
+	modifiche map[string]bool
+	prezzo    *money.Importo
+}
+
+// EsportaCSV scrive i piatti dell'inventario in formato CSV, ordinati per portata e nome.
+// Le modifiche consentite sono separate da ";" e precedute da "+" o "-" (es. "+formaggio;-basilico")
+func (inv *Inventory) EsportaCSV(w io.Writer) error {
+	inv.mu.RLock()
+	piatti := make([]Piatto, 0, len(inv.piatti))
+	for _, piatto := range inv.piatti {
+		piatti = append(piatti, piatto)
+	}
+	inv.mu.RUnlock()
+
+	sort.Slice(piatti, func(i, j int) bool {
+		if piatti[i].Portata != piatti[j].Portata {
+			return ordinePortate[piatti[i].Portata] < ordinePortate[piatti[j].Portata]
+		}
+		return piatti[i].Nome < piatti[j].Nome
+	})
+
+	scrittore := csv.NewWriter(w)
+	if err := scrittore.Write(colonneCSV); err != nil {
+		return err
+	}
+	for _, piatto := range piatti {
+		modifiche := make([]string, 0, len(piatto.ModificheConsentite))
+		for _, voce := range ordinaChiavi(piatto.ModificheConsentite) {
+			tipo := "-"
+			if piatto.ModificheConsentite[voce] {
+				tipo = "+"
+			}
+			modifiche = append(modifiche, tipo+voce)
+		}
+
+		prezzo, _ := piatto.Prezzo.MarshalText()
+		err := scrittore.Write([]string{
+			piatto.Nome,
+			piatto.Portata,
+			strconv.FormatFloat(piatto.Disponibilita, 'f', -1, 64),
+			strings.Join(modifiche, ";"),
+			string(prezzo),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	scrittore.Flush()
+
+	return scrittore.Error()
+}
+
+// LoadFromCSV carica un inventario con i soli piatti del file CSV, senza ricette né dispensa.
+// Tutte le colonne sono obbligatorie tranne modifiche
+func LoadFromCSV(path string) (*Inventory, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("errore nella lettura del file CSV: %w", err)
+	}
+
+	righe, errori, err := leggiCSV(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	for _, riga := range righe {
+		errori = append(errori, riga.campiMancanti(ColonnaPortata, ColonnaPorzioni, ColonnaPrezzo)...)
+	}
+	if len(errori) > 0 {
+		return nil, fmt.Errorf("%s: %w", path, errori.ordinati())
+	}
+
+	inv := New()
+	for _, riga := range righe {
+		err := inv.SetPiatto(Piatto{
+			Nome:                riga.nome,
+			Portata:             riga.portata,
+			Disponibilita:       *riga.porzioni,
+			Prezzo:              *riga.prezzo,
+			ModificheConsentite: riga.modifiche,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return inv, nil
+}
+
+// ImportaCSV unisce il file CSV all'inventario: aggiorna i piatti presenti, aggiunge quelli nuovi
+// e lascia invariati quelli non citati. Per i piatti presenti le celle vuote mantengono il valore
+// attuale, e ricette, varianti e supplementi non cambiano. Se una riga non è valida l'inventario
+// non viene modificato; le porzioni cambiate sono registrate come rettifiche
+func (inv *Inventory) ImportaCSV(r io.Reader, operatore string) (ImportazioneCSV, error) {
+	if operatore == "" {
+		return ImportazioneCSV{}, fmt.Errorf("indicare l'operatore che esegue l'importazione")
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return ImportazioneCSV{}, fmt.Errorf("errore nella lettura del file CSV: %w", err)
+	}
+	righe, errori, err := leggiCSV(data)
+	if err != nil {
+		return ImportazioneCSV{}, err
+	}
+
+	inv.mu.Lock()
+
+	var (
+		importati []Piatto
+		movimenti []Movimento
+		riepilogo ImportazioneCSV
+	)
+	for _, riga := range righe {
+		piatto, exists := inv.piatti[riga.nome]
+		if !exists {
+			if mancanti := riga.campiMancanti(ColonnaPortata, ColonnaPorzioni, ColonnaPrezzo); len(mancanti) > 0 {
+				errori = append(errori, mancanti...)
+				continue
+			}
+			importati = append(importati, Piatto{
+				Nome:                riga.nome,
+				Portata:             riga.portata,
+				Disponibilita:       *riga.porzioni,
+				Prezzo:              *riga.prezzo,
+				ModificheConsentite: riga.modifiche,
+			})
+			movimenti = append(movimenti, Movimento{
+				Tipo:      MovimentoInserimento,
+				Piatto:    riga.nome,
+				Quantita:  *riga.porzioni,
+				Operatore: operatore,
+				Motivo:    "importazione CSV",
+			})
+			riepilogo.Aggiunti = append(riepilogo.Aggiunti, riga.nome)
+			continue
+		}
+
+		aggiornato, err := riga.aggiorna(piatto)
+		if err != nil {
+			errori = append(errori, *err)
+			continue
+		}
+		cambiato := !piattiUguali(piatto, aggiornato)
+		if riga.porzioni != nil && *riga.porzioni != piatto.Disponibilita {
+			movimenti = append(movimenti, Movimento{
+				Tipo:      MovimentoRettifica,
+				Piatto:    riga.nome,
+				Quantita:  *riga.porzioni,
+				Operatore: operatore,
+				Motivo:    fmt.Sprintf("importazione CSV, erano %g porzioni", piatto.Disponibilita),
+			})
+			cambiato = true
+		}
+		if !cambiato {
+			riepilogo.Invariati = append(riepilogo.Invariati, riga.nome)
+			continue
+		}
+		importati = append(importati, aggiornato)
+		riepilogo.Aggiornati = append(riepilogo.Aggiornati, riga.nome)
+	}
+
+	if len(errori) > 0 {
+		inv.mu.Unlock()
+		return ImportazioneCSV{}, errori.ordinati()
+	}
+	if len(movimenti) > 0 {
+		if err := inv.registra(movimenti...); err != nil {
+			inv.mu.Unlock()
+			return ImportazioneCSV{}, err
+		}
+	}
+	prima := inv.statiPrima(movimenti)
+	for _, piatto := range importati {
+		inv.piatti[piatto.Nome] = piatto
+	}
+	for _, m := range movimenti {
+		inv.applica(m)
+	}
+	eventi := inv.eventiMovimenti(prima)
+
+	inv.mu.Unlock()
+	inv.notifica(eventi)
+
+	return riepilogo, nil
+}
+
+// aggiorna applica al piatto le celle non vuote della riga, tranne le porzioni che
+// passano da una rettifica. Le voci con supplementi o aggiunte devono restare aggiungibili
+func (riga rigaCSV) aggiorna(piatto Piatto) (Piatto, *ErroreRiga) {
+	if riga.portata != "" {
+		piatto.Portata = riga.portata
+		piatto.AltrePortate = slices.DeleteFunc(slices.Clone(piatto.AltrePortate), func(p string) bool { return p == riga.portata })
+	}
+	if riga.prezzo != nil {
+		piatto.Prezzo = *riga.prezzo
+	}
+	if riga.modifiche != nil {
+		voci := append(ordinaChiavi(piatto.Supplementi), ordinaChiavi(piatto.Aggiunte)...)
+		for _, voce := range voci {
+			if !riga.modifiche[voce] {
+				return Piatto{}, &ErroreRiga{riga.riga, ColonnaModifiche, fmt.Sprintf("la voce %q ha un supplemento o un'aggiunta e deve restare tra le modifiche con \"+\"", voce)}
+			}
+		}
+		piatto.ModificheConsentite = riga.modifiche
+	}
+	return piatto, nil
+}
+
+// campiMancanti segnala le colonne indicate lasciate vuote
+func (riga rigaCSV) campiMancanti(colonne ...string) []ErroreRiga {
+	var errori []ErroreRiga
+	for _, colonna := range colonne {
+		vuota := false
+		switch colonna {
+		case ColonnaPortata:
+			vuota = riga.portata == ""
+		case ColonnaPorzioni:
+			vuota = riga.porzioni == nil
+		case ColonnaPrezzo:
+			vuota = riga.prezzo == nil
+		}
+		if vuota {
+			errori = append(errori, ErroreRiga{riga.riga, colonna, "il campo è obbligatorio"})
+		}
+	}
+	return errori
+}
+
+// leggiCSV legge le righe del file, restituendo a parte gli errori di quelle non valide.
+// Il separatore può essere "," o ";", come lo esportano i fogli di calcolo, e l'intestazione
+// indica l'ordine delle colonne
+func leggiCSV(data []byte) ([]rigaCSV, ErroriCSV, error) {
+	lettore := csv.NewReader(bytes.NewReader(data))
+	lettore.FieldsPerRecord = -1
+	lettore.TrimLeadingSpace = true
+	if primaRiga, _, _ := bytes.Cut(data, []byte("\n")); bytes.Count(primaRiga, []byte(";")) > bytes.Count(primaRiga, []byte(",")) {
+		lettore.Comma = ';'
+	}
+
+	intestazione, err := lettore.Read()
+	if err == io.EOF {
+		return nil, nil, fmt.Errorf("il file CSV è vuoto")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("intestazione non valida: %w", err)
+	}
+	indici, err := indiciColonne(intestazione)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		righe  []rigaCSV
+		errori ErroriCSV
+		visti  = make(map[string]int)
+	)
+	for {
+		record, err := lettore.Read()
+		if err == io.EOF {
+			break
+		}
+		// Il lettore riprende dal record successivo, così vengono segnalate tutte le righe non valide
+		var errParse *csv.ParseError
+		if stderrors.As(err, &errParse) {
+			errori = append(errori, ErroreRiga{Riga: errParse.Line, Messaggio: errParse.Err.Error()})
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		numero, _ := lettore.FieldPos(0)
+		if len(record) == 1 && strings.TrimSpace(record[0]) == "" {
+			continue
+		}
+		if len(record) != len(intestazione) {
+			errori = append(errori, ErroreRiga{Riga: numero, Messaggio: fmt.Sprintf("attese %d colonne, trovate %d", len(intestazione), len(record))})
+			continue
+		}
+
+		riga, erroriRiga := validaRigaCSV(numero, record, indici)
+		if len(erroriRiga) > 0 {
+			errori = append(errori, erroriRiga...)
+			continue
+		}
+		if precedente, duplicato := visti[riga.nome]; duplicato {
+			errori = append(errori, ErroreRiga{numero, ColonnaPiatto, fmt.Sprintf("piatto %q già presente alla riga %d", riga.nome, precedente)})
+			continue
+		}
+		visti[riga.nome] = numero
+		righe = append(righe, riga)
+	}
+
+	if len(righe) == 0 && len(errori) == 0 {
+		return nil, nil, fmt.Errorf("il file CSV non contiene piatti")
+	}
+	return righe, errori, nil
+}
+
+// indiciColonne associa ogni colonna nota alla sua posizione nell'intestazione
+func indiciColonne(intestazione []string) (map[string]int, error) {
+	indici := make(map[string]int, len(intestazione))
+	for i, nome := range intestazione {
+		nome = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(nome, "\ufeff")))
+		if !slices.Contains(colonneCSV, nome) {
+			return nil, ErroriCSV{{1, nome, fmt.Sprintf("colonna sconosciuta, usare %s", strings.Join(colonneCSV, ", "))}}
+		}
+		if _, duplicata := indici[nome]; duplicata {
+			return nil, ErroriCSV{{1, nome, "colonna duplicata"}}
+		}
+		indici[nome] = i
+	}
+	if _, exists := indici[ColonnaPiatto]; !exists {
+		return nil, ErroriCSV{{1, ColonnaPiatto, "colonna obbligatoria mancante"}}
+	}
+	return indici, nil
+}
+
+// validaRigaCSV converte una riga del file, raccogliendo gli errori di tutte le sue celle
+func validaRigaCSV(numero int, record []string, indici map[string]int) (rigaCSV, []ErroreRiga) {
+	cella := func(colonna string) string {
+		if i, exists := indici[colonna]; exists {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+	riga := rigaCSV{riga: numero, nome: cella(ColonnaPiatto)}
+	var errori []ErroreRiga
+
+	if riga.nome == "" {
+		errori = append(errori, ErroreRiga{numero, ColonnaPiatto, "il campo è obbligatorio"})
+	} else if strings.Contains(riga.nome, `"`) {
+		errori = append(errori, ErroreRiga{numero, ColonnaPiatto, "il nome non può contenere virgolette"})
+	}
+
+	if portata := strings.ToUpper(cella(ColonnaPortata)); portata != "" {
+		if portataValida(portata) {
+			riga.portata = portata
+		} else {
+			errori = append(errori, ErroreRiga{numero, ColonnaPortata, fmt.Sprintf("portata %q non valida, usare PRIMO, SECONDO o CONTORNO", portata)})
+		}
+	}
+
+	if testo := cella(ColonnaPorzioni); testo != "" {
+		porzioni, err := strconv.ParseFloat(strings.Replace(testo, ",", ".", 1), 64)
+		switch {
+		case err != nil:
+			errori = append(errori, ErroreRiga{numero, ColonnaPorzioni, fmt.Sprintf("valore %q non valido", testo)})
+		case porzioni < 0:
+			errori = append(errori, ErroreRiga{numero, ColonnaPorzioni, fmt.Sprintf("le porzioni non possono essere negative (%g)", porzioni)})
+		default:
+			riga.porzioni = &porzioni
+		}
+	}
+
+	if testo := cella(ColonnaPrezzo); testo != "" {
+		prezzo, err := parsePrezzo(strings.TrimSpace(strings.TrimSuffix(testo, "€")))
+		if err != nil {
+			errori = append(errori, ErroreRiga{numero, ColonnaPrezzo, err.Error()})
+		} else {
+			riga.prezzo = &prezzo
+		}
+	}
+
+	if testo := cella(ColonnaModifiche); testo != "" {
+		riga.modifiche = make(map[string]bool)
+		for _, voce := range strings.Split(testo, ";") {
+			voce = strings.TrimSpace(voce)
+			if voce == "" {
+				continue
+			}
+			nome := strings.TrimSpace(voce[1:])
+			if (voce[0] != '+' && voce[0] != '-') || nome == "" {
+				errori = append(errori, ErroreRiga{numero, ColonnaModifiche, fmt.Sprintf("modifica %q non valida, usare +voce o -voce separate da \";\"", voce)})
+				continue
+			}
+			riga.modifiche[nome] = voce[0] == '+'
+		}
+	}
+
+	return riga, errori
+}
+
+// piattiUguali confronta i campi del piatto gestiti dal file CSV, escluse le porzioni
+func piattiUguali(a, b Piatto) bool {
+	if a.Portata != b.Portata || a.Prezzo != b.Prezzo || len(a.ModificheConsentite) != len(b.ModificheConsentite) {
+		return false
+	}
+	for voce, tipo := range a.ModificheConsentite {
+		if altro, exists := b.ModificheConsentite[voce]; !exists || altro != tipo {
+			return false
+		}
+	}
+	return true
+}
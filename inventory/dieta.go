@@ -0,0 +1,198 @@
+package inventory
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/branila/restaurant-protocol/models"
+)
+
+// Categorie alimentari degli ingredienti, da cui si ricavano le indicazioni alimentari dei piatti
+const (
+	CategoriaCarne     = "carne"
+	CategoriaPesce     = "pesce"
+	CategoriaLatticini = "latticini"
+	CategoriaUova      = "uova"
+	CategoriaGlutine   = "glutine"
+	CategoriaNessuna   = "nessuna" // la voce non rientra in nessuna delle altre categorie
+)
+
+// Indicazioni alimentari ricavate dagli ingredienti
+const (
+	TagVegano       = "vegano"
+	TagVegetariano  = "vegetariano"
+	TagSenzaGlutine = "senza glutine"
+)
+
+// tagAlimentari elenca, per ogni indicazione, le categorie che la escludono
+var tagAlimentari = []struct {
+	tag        string
+	esclusioni []string
+}{
+	{TagVegano, []string{CategoriaCarne, CategoriaPesce, CategoriaLatticini, CategoriaUova}},
+	{TagVegetariano, []string{CategoriaCarne, CategoriaPesce}},
+	{TagSenzaGlutine, []string{CategoriaGlutine}},
+}
+
+// tagRicavato indica se il tag è un'indicazione alimentare, che si ricava dagli ingredienti e non si dichiara
+func tagRicavato(tag string) bool {
+	for _, t := range tagAlimentari {
+		if t.tag == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// CategoriaValida indica se la categoria alimentare è tra quelle riconosciute
+func CategoriaValida(categoria string) bool {
+	switch categoria {
+	case CategoriaCarne, CategoriaPesce, CategoriaLatticini, CategoriaUova, CategoriaGlutine, CategoriaNessuna:
+		return true
+	}
+	return false
+}
+
+// SetCategorie associa le categorie alimentari a una voce: un ingrediente della ricetta,
+// una voce rimovibile o un'aggiunta (es. "burro" -> latticini). Senza categorie, o con
+// CategoriaNessuna, la voce è classificata come vegana e senza glutine. Le indicazioni
+// alimentari si ricavano solo per i piatti con tutte le voci classificate
+func (inv *Inventory) SetCategorie(voce string, categorie ...string) error {
+	var contiene []string
+	for _, categoria := range categorie {
+		if !CategoriaValida(categoria) {
+			return fmt.Errorf("categoria alimentare %q non valida, usare carne, pesce, latticini, uova, glutine o nessuna", categoria)
+		}
+		if categoria != CategoriaNessuna {
+			contiene = append(contiene, categoria)
+		}
+	}
+	if len(contiene) > 0 && len(contiene) < len(categorie) {
+		return fmt.Errorf("la voce %q non può essere nella categoria %s e in altre categorie", voce, CategoriaNessuna)
+	}
+
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	inv.categorie[voce] = contiene
+	return nil
+}
+
+// TagAlimentari restituisce le indicazioni alimentari del piatto con le modifiche indicate,
+// insieme ai tag dichiarati nel menu. Rimuovere o aggiungere una voce può cambiare il risultato
+func (inv *Inventory) TagAlimentari(nome string, modifiche []models.Modifica) []string {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	piatto, exists := inv.piatti[nome]
+	if !exists {
+		return nil
+	}
+	return inv.tagPiatto(piatto, modifiche)
+}
+
+// tagPiatto unisce i tag dichiarati e quelli ricavati dalla composizione del piatto. Le indicazioni
+// alimentari dichiarate a mano vengono ignorate: valgono solo quelle ricavate, che seguono le modifiche.
+// Va chiamata con il lock già acquisito
+func (inv *Inventory) tagPiatto(piatto Piatto, modifiche []models.Modifica) []string {
+	tag := slices.DeleteFunc(slices.Clone(piatto.Tag), tagRicavato)
+
+	// Senza categorie non è possibile dire nulla sulla composizione dei piatti
+	if len(inv.categorie) == 0 {
+		return tag
+	}
+
+	// Una voce non classificata potrebbe contenere qualunque categoria
+	presenti, classificate := inv.categoriePresenti(composizione(piatto, modifiche))
+	if !classificate {
+		return tag
+	}
+	for _, t := range tagAlimentari {
+		if !slices.ContainsFunc(t.esclusioni, func(c string) bool { return presenti[c] }) && !slices.Contains(tag, t.tag) {
+			tag = append(tag, t.tag)
+		}
+	}
+
+	return tag
+}
+
+// tagSuRichiesta indica, per ogni indicazione alimentare che il piatto non ha, quali voci
+// rimuovere per ottenerla. Va chiamata con il lock già acquisito
+func (inv *Inventory) tagSuRichiesta(piatto Piatto) map[string][]string {
+	if len(inv.categorie) == 0 {
+		return nil
+	}
+
+	base := inv.tagPiatto(piatto, nil)
+	var suRichiesta map[string][]string
+
+	for _, t := range tagAlimentari {
+		if slices.Contains(base, t.tag) {
+			continue
+		}
+
+		var rimozioni []models.Modifica
+		for _, voce := range ordinaChiavi(piatto.ModificheConsentite) {
+			if piatto.ModificheConsentite[voce] {
+				continue
+			}
+			if slices.ContainsFunc(inv.categorie[voce], func(c string) bool { return slices.Contains(t.esclusioni, c) }) {
+				rimozioni = append(rimozioni, models.Modifica{Tipo: "-", Voce: voce})
+			}
+		}
+		if len(rimozioni) == 0 || !slices.Contains(inv.tagPiatto(piatto, rimozioni), t.tag) {
+			continue
+		}
+
+		if suRichiesta == nil {
+			suRichiesta = make(map[string][]string)
+		}
+		for _, mod := range rimozioni {
+			suRichiesta[t.tag] = append(suRichiesta[t.tag], mod.Voce)
+		}
+	}
+
+	return suRichiesta
+}
+
+// composizione restituisce le voci presenti nel piatto con le modifiche indicate:
+// gli ingredienti della ricetta e le voci rimovibili, meno quelle rimosse, più le aggiunte
+func composizione(piatto Piatto, modifiche []models.Modifica) map[string]bool {
+	voci := make(map[string]bool, len(piatto.Ricetta)+len(piatto.ModificheConsentite))
+	for ingrediente := range piatto.Ricetta {
+		voci[ingrediente] = true
+	}
+	for voce, aggiunta := range piatto.ModificheConsentite {
+		if !aggiunta {
+			voci[voce] = true
+		}
+	}
+
+	for _, mod := range modifiche {
+		switch mod.Tipo {
+		case "-":
+			delete(voci, mod.Voce)
+		case "+":
+			voci[mod.Voce] = true
+		}
+	}
+
+	return voci
+}
+
+// categoriePresenti raccoglie le categorie alimentari delle voci indicate e indica
+// se tutte le voci sono classificate. Va chiamata con il lock già acquisito
+func (inv *Inventory) categoriePresenti(voci map[string]bool) (map[string]bool, bool) {
+	presenti := make(map[string]bool)
+	classificate := true
+	for voce := range voci {
+		categorie, exists := inv.categorie[voce]
+		if !exists {
+			classificate = false
+		}
+		for _, categoria := range categorie {
+			presenti[categoria] = true
+		}
+	}
+	return presenti, classificate
+}
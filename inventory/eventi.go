@@ -0,0 +1,211 @@
+package inventory
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// SogliaPredefinita è il numero di porzioni sotto il quale un piatto è considerato in esaurimento
+const SogliaPredefinita = 3
+
+// Tipi di evento dell'inventario
+const (
+	EventoScortaBassa = "scorta_bassa"
+	EventoEsaurito    = "esaurito"
+	EventoRifornito   = "rifornito"
+)
+
+// Evento segnala un cambiamento rilevante nella disponibilità di un piatto
+type Evento struct {
+	Tipo      string
+	Piatto    string
+	Rimanenti float64 // porzioni servibili, tenendo conto anche degli ingredienti
+	Soglia    int
+	Timestamp time.Time
+}
+
+// statoScorta è la disponibilità di un piatto su cui si basano gli eventi: un evento
+// viene emesso solo quando lo stato cambia
+type statoScorta struct {
+	rimanenti float64
+	esaurito  bool // sospeso, senza porzioni o senza ingredienti per una porzione
+	bassa     bool
+}
+
+func (e Evento) String() string {
+	switch e.Tipo {
+	case EventoScortaBassa:
+		return fmt.Sprintf("Attenzione: rimangono solo %g porzioni di %s", e.Rimanenti, e.Piatto)
+	case EventoEsaurito:
+		return fmt.Sprintf("Esaurito: %s non è più disponibile", e.Piatto)
+	case EventoRifornito:
+		return fmt.Sprintf("Rifornito: %s di nuovo disponibile (%g porzioni)", e.Piatto, e.Rimanenti)
+	default:
+		return fmt.Sprintf("%s: %s (%g porzioni)", e.Tipo, e.Piatto, e.Rimanenti)
+	}
+}
+
+// Ascoltatore riceve gli eventi dell'inventario. Viene chiamato dopo il rilascio del lock,
+// quindi può interrogare l'inventario, ma non deve bloccare a lungo
+type Ascoltatore func(Evento)
+
+type sottoscrizione struct {
+	id          int
+	ascoltatore Ascoltatore
+}
+
+// Sottoscrivi registra un ascoltatore e restituisce la funzione per annullare la sottoscrizione
+func (inv *Inventory) Sottoscrivi(ascoltatore Ascoltatore) func() {
+	inv.muEventi.Lock()
+	defer inv.muEventi.Unlock()
+
+	inv.prossimoID++
+	id := inv.prossimoID
+	inv.sottoscrizioni = append(inv.sottoscrizioni, sottoscrizione{id: id, ascoltatore: ascoltatore})
+
+	return func() {
+		inv.muEventi.Lock()
+		defer inv.muEventi.Unlock()
+
+		for i, s := range inv.sottoscrizioni {
+			if s.id == id {
+				inv.sottoscrizioni = append(inv.sottoscrizioni[:i], inv.sottoscrizioni[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// SetSoglia imposta la soglia di scorta bassa di un piatto; 0 disattiva l'avviso
+func (inv *Inventory) SetSoglia(nomePiatto string, soglia int) error {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	piatto, exists := inv.piatti[nomePiatto]
+	if !exists {
+		return fmt.Errorf("il piatto '%s' non esiste nel menu", nomePiatto)
+	}
+	if soglia < 0 {
+		return fmt.Errorf("la soglia non può essere negativa (%d)", soglia)
+	}
+
+	piatto.SogliaScortaBassa = &soglia
+	inv.piatti[nomePiatto] = piatto
+
+	return nil
+}
+
+// soglia restituisce la soglia di scorta bassa del piatto, o quella predefinita se non è indicata
+func (p Piatto) soglia() int {
+	if p.SogliaScortaBassa == nil {
+		return SogliaPredefinita
+	}
+	return *p.SogliaScortaBassa
+}
+
+// statoPiatto calcola la disponibilità del piatto con le porzioni e le scorte di oggi.
+// Va chiamata con il lock già acquisito
+func (inv *Inventory) statoPiatto(piatto Piatto) statoScorta {
+	stato := statoScorta{rimanenti: piatto.Disponibilita}
+	adesso := time.Now()
+
+	for ingrediente, dose := range inv.fabbisogno(piatto, nil) {
+		if dose <= 0 {
+			continue
+		}
+		scorta := inv.ingredienti[ingrediente].DisponibileAl(adesso)
+		if scorta < dose {
+			stato.esaurito = true
+		}
+		stato.rimanenti = min(stato.rimanenti, math.Floor(arrotondaPorzioni(scorta/dose)))
+	}
+
+	stato.rimanenti = max(stato.rimanenti, 0)
+	stato.esaurito = stato.esaurito || piatto.Sospeso || piatto.Disponibilita <= 0
+	stato.bassa = !stato.esaurito && stato.rimanenti <= float64(piatto.soglia())
+	return stato
+}
+
+// statiPrima registra lo stato dei piatti toccati dai movimenti, direttamente o attraverso
+// gli ingredienti della ricetta. Va chiamata con il lock già acquisito, prima di applicarli
+func (inv *Inventory) statiPrima(movimenti []Movimento) map[string]statoScorta {
+	ingredienti := make(map[string]bool)
+	stati := make(map[string]statoScorta)
+	for _, m := range movimenti {
+		if m.Ingrediente != "" {
+			ingredienti[m.Ingrediente] = true
+		} else if piatto, exists := inv.piatti[m.Piatto]; exists {
+			stati[m.Piatto] = inv.statoPiatto(piatto)
+		}
+	}
+
+	if len(ingredienti) > 0 {
+		for nome, piatto := range inv.piatti {
+			if _, visto := stati[nome]; visto {
+				continue
+			}
+			for chiave := range inv.fabbisogno(piatto, nil) {
+				if ingredienti[chiave] {
+					stati[nome] = inv.statoPiatto(piatto)
+					break
+				}
+			}
+		}
+	}
+
+	return stati
+}
+
+// eventiMovimenti confronta lo stato dei piatti prima e dopo i movimenti appena applicati
+// ed emette un evento per ogni piatto che è finito, tornato disponibile o sceso sotto la soglia.
+// Va chiamata con il lock già acquisito
+func (inv *Inventory) eventiMovimenti(prima map[string]statoScorta) []Evento {
+	var eventi []Evento
+	adesso := time.Now()
+
+	for _, nome := range ordinaChiavi(prima) {
+		piatto, exists := inv.piatti[nome]
+		if !exists {
+			continue
+		}
+		stato := inv.statoPiatto(piatto)
+		evento := Evento{
+			Piatto:    nome,
+			Rimanenti: stato.rimanenti,
+			Soglia:    piatto.soglia(),
+			Timestamp: adesso,
+		}
+
+		switch {
+		case stato.esaurito && !prima[nome].esaurito:
+			evento.Tipo = EventoEsaurito
+		case !stato.esaurito && prima[nome].esaurito:
+			evento.Tipo = EventoRifornito
+		case stato.bassa && !prima[nome].bassa:
+			evento.Tipo = EventoScortaBassa
+		default:
+			continue
+		}
+		eventi = append(eventi, evento)
+	}
+
+	return eventi
+}
+
+// notifica consegna gli eventi agli ascoltatori. Va chiamata senza il lock dell'inventario
+func (inv *Inventory) notifica(eventi []Evento) {
+	if len(eventi) == 0 {
+		return
+	}
+
+	inv.muEventi.Lock()
+	sottoscrizioni := append([]sottoscrizione(nil), inv.sottoscrizioni...)
+	inv.muEventi.Unlock()
+
+	for _, evento := range eventi {
+		for _, s := range sottoscrizioni {
+			s.ascoltatore(evento)
+		}
+	}
+}
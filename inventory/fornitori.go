@@ -0,0 +1,112 @@
+package inventory
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Fornitore è chi rifornisce uno o più ingredienti della dispensa
+type Fornitore struct {
+	Nome     string
+	Telefono string `json:",omitempty" yaml:",omitempty"`
+	Email    string `json:",omitempty" yaml:",omitempty"`
+}
+
+// RigaAcquisto è un ingrediente da riordinare per riportarlo al livello di riferimento
+type RigaAcquisto struct {
+	Ingrediente string
+	Unita       string
+	Scorta      float64 // scorta utilizzabile, esclusi i lotti scaduti, sommata sulle stazioni sotto il livello
+	Par         float64 // somma dei livelli di riferimento delle stesse scorte
+	Quantita    float64 // da ordinare, arrotondata alle confezioni intere
+}
+
+// OrdineAcquisto raccoglie gli ingredienti da ordinare a uno stesso fornitore
+type OrdineAcquisto struct {
+	Fornitore Fornitore // con il nome vuoto per gli ingredienti senza fornitore
+	Data      string    // DD/MM/YYYY
+	Righe     []RigaAcquisto
+}
+
+// SetFornitore inserisce o sostituisce un fornitore
+func (inv *Inventory) SetFornitore(fornitore Fornitore) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	inv.fornitori[fornitore.Nome] = fornitore
+}
+
+// GetFornitore restituisce il fornitore indicato
+func (inv *Inventory) GetFornitore(nome string) (Fornitore, bool) {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	fornitore, exists := inv.fornitori[nome]
+	return fornitore, exists
+}
+
+// OrdiniAcquisto prepara un ordine per fornitore con tutti gli ingredienti sotto il livello
+// di riferimento nel giorno indicato. Gli ingredienti senza fornitore finiscono in un ordine
+// a parte, in fondo all'elenco
+func (inv *Inventory) OrdiniAcquisto(giorno time.Time) []OrdineAcquisto {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	// Le scorte dello stesso ingrediente in più stazioni formano una sola riga per fornitore:
+	// si somma quanto manca a ciascuna e solo dopo si arrotonda alle confezioni intere
+	type voce struct{ fornitore, ingrediente string }
+	var voci []voce
+	righe := make(map[voce]*RigaAcquisto)
+	confezioni := make(map[voce]float64)
+	for _, chiave := range ordinaChiavi(inv.ingredienti) {
+		ingrediente := inv.ingredienti[chiave]
+		scorta := ingrediente.DisponibileAl(giorno)
+		if ingrediente.Par <= 0 || scorta >= ingrediente.Par {
+			continue
+		}
+
+		v := voce{ingrediente.Fornitore, ingrediente.Nome}
+		riga, exists := righe[v]
+		if !exists {
+			riga = &RigaAcquisto{Ingrediente: ingrediente.Nome, Unita: ingrediente.Unita}
+			righe[v] = riga
+			voci = append(voci, v)
+		}
+		riga.Scorta += scorta
+		riga.Par += ingrediente.Par
+		confezioni[v] = max(confezioni[v], ingrediente.Confezione)
+	}
+
+	perFornitore := make(map[string][]RigaAcquisto)
+	for _, v := range voci {
+		riga := *righe[v]
+		quantita := riga.Par - riga.Scorta
+		if confezione := confezioni[v]; confezione > 0 {
+			quantita = math.Ceil(arrotondaPorzioni(quantita/confezione)) * confezione
+		}
+		riga.Quantita = arrotondaPorzioni(quantita)
+		perFornitore[v.fornitore] = append(perFornitore[v.fornitore], riga)
+	}
+	for _, righe := range perFornitore {
+		sort.Slice(righe, func(i, j int) bool { return righe[i].Ingrediente < righe[j].Ingrediente })
+	}
+
+	ordini := make([]OrdineAcquisto, 0, len(perFornitore))
+	for nome, righe := range perFornitore {
+		fornitore, exists := inv.fornitori[nome]
+		if !exists {
+			fornitore = Fornitore{Nome: nome}
+		}
+		ordini = append(ordini, OrdineAcquisto{Fornitore: fornitore, Data: giorno.Format(FormatoData), Righe: righe})
+	}
+	sort.Slice(ordini, func(i, j int) bool {
+		a, b := ordini[i].Fornitore.Nome, ordini[j].Fornitore.Nome
+		if (a == "") != (b == "") {
+			return b == ""
+		}
+		return a < b
+	})
+
+	return ordini
+}
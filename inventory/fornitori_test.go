@@ -0,0 +1,41 @@
+package inventory
+
+import (
+	"testing"
+	"time"
+)
+
+// Un ingrediente tenuto in più stazioni va ordinato in una sola riga, con il nome
+// dell'ingrediente e le confezioni calcolate su quanto manca in tutte le stazioni
+func TestOrdiniAcquistoSommaLeStazioni(t *testing.T) {
+	inv := New()
+	inv.SetStazione(Stazione{Nome: "griglia"})
+	inv.SetStazione(Stazione{Nome: "insalate"})
+	inv.SetFornitore(Fornitore{Nome: "Drogheria"})
+
+	for _, ingrediente := range []Ingrediente{
+		// mancano 200 g alla griglia e 200 g alle insalate: basta una confezione da 500 g, non una per stazione
+		{Nome: "sale", Quantita: 300, Unita: UnitaGrammi, Stazione: "griglia", Par: 500, Fornitore: "Drogheria", Confezione: 500},
+		{Nome: "sale", Quantita: 200, Unita: UnitaGrammi, Stazione: "insalate", Par: 400, Fornitore: "Drogheria", Confezione: 500},
+		// sopra il livello: non entra nella riga
+		{Nome: "sale", Quantita: 900, Unita: UnitaGrammi, Par: 500, Fornitore: "Drogheria", Confezione: 500},
+	} {
+		if err := inv.SetIngrediente(ingrediente); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ordini := inv.OrdiniAcquisto(time.Now())
+	if len(ordini) != 1 || ordini[0].Fornitore.Nome != "Drogheria" {
+		t.Fatalf("attesi un ordine alla Drogheria, ottenuti %+v", ordini)
+	}
+	if len(ordini[0].Righe) != 1 {
+		t.Fatalf("attesa una riga, ottenute %+v", ordini[0].Righe)
+	}
+
+	riga := ordini[0].Righe[0]
+	atteso := RigaAcquisto{Ingrediente: "sale", Unita: UnitaGrammi, Scorta: 500, Par: 900, Quantita: 500}
+	if riga != atteso {
+		t.Fatalf("riga %+v, attesa %+v", riga, atteso)
+	}
+}
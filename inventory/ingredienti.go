@@ -0,0 +1,245 @@
+package inventory
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/branila/restaurant-protocol/errors"
+	"github.com/branila/restaurant-protocol/models"
+	"github.com/branila/restaurant-protocol/units"
+)
+
+// Unità di misura in cui sono tenute le scorte degli ingredienti. Le altre unità
+// ammesse da units (es. kg, l) vengono convertite in queste
+const (
+	UnitaGrammi     = string(units.Grammi)
+	UnitaMillilitri = string(units.Millilitri)
+	UnitaPezzi      = string(units.Pezzi)
+)
+
+// Ingrediente è una scorta condivisa tra i piatti che lo usano nella ricetta. Lo stesso
+// ingrediente può avere una scorta nella dispensa comune e una in ogni stazione
+type Ingrediente struct {
+	Nome     string
+	Quantita float64 // scorta totale, compresa quella attribuita ai lotti
+	Unita    string  // g, ml o pz; vedi SetIngrediente per le altre unità
+	Lotti    []Lotto
+	Stazione string // stazione che detiene la scorta; vuota = dispensa comune a tutte
+
+	Par        float64 // livello di riferimento: sotto questa scorta l'ingrediente va riordinato; 0 = mai
+	Fornitore  string
+	Confezione float64 // quantità di una confezione del fornitore: si ordinano confezioni intere; 0 = sfuso
+}
+
+// SetIngrediente inserisce o sostituisce la scorta di un ingrediente nella sua stazione. Le quantità
+// espresse in un'unità diversa da g, ml o pz (es. kg) vengono convertite nell'unità base.
+// L'inserimento viene registrato nello storico con la scorta iniziale
+func (inv *Inventory) SetIngrediente(ingrediente Ingrediente) error {
+	ingrediente, err := ingrediente.inUnitaBase()
+	if err != nil {
+		return err
+	}
+
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	if err := inv.verificaStazioneIngrediente(ingrediente); err != nil {
+		return err
+	}
+
+	chiave := ingrediente.chiave()
+	movimenti := []Movimento{{Tipo: MovimentoInserimento, Ingrediente: chiave, Quantita: ingrediente.Quantita}}
+	if err := inv.registra(movimenti...); err != nil {
+		return err
+	}
+	inv.ingredienti[chiave] = ingrediente
+	inv.applica(movimenti[0])
+
+	return nil
+}
+
+// GetIngrediente cerca una scorta per chiave ("stazione/nome") o per nome, se l'ingrediente
+// ha una sola scorta
+func (inv *Inventory) GetIngrediente(nome string) (Ingrediente, bool) {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	chiave, err := inv.scorta(nome)
+	if err != nil {
+		return Ingrediente{}, false
+	}
+	return inv.ingredienti[chiave], true
+}
+
+// chiaveScorta identifica la scorta di un ingrediente nei movimenti e nello stato salvato:
+// il nome per la dispensa comune, "stazione/nome" per la scorta di una stazione
+func chiaveScorta(stazione, nome string) string {
+	if stazione == "" {
+		return nome
+	}
+	return stazione + "/" + nome
+}
+
+func (i Ingrediente) chiave() string {
+	return chiaveScorta(i.Stazione, i.Nome)
+}
+
+// scorta trova la scorta indicata dalla chiave o dal solo nome dell'ingrediente, se questo
+// ha una sola scorta. Va chiamata con il lock già acquisito
+func (inv *Inventory) scorta(riferimento string) (string, error) {
+	if _, exists := inv.ingredienti[riferimento]; exists {
+		return riferimento, nil
+	}
+
+	trovate := inv.scorteDi(riferimento)
+	switch len(trovate) {
+	case 0:
+		return "", fmt.Errorf("l'ingrediente '%s' non è presente in dispensa", riferimento)
+	case 1:
+		return trovate[0], nil
+	}
+	return "", fmt.Errorf("l'ingrediente '%s' ha una scorta in più stazioni, indicarne una: %s", riferimento, strings.Join(trovate, ", "))
+}
+
+// scorteDi restituisce le chiavi di tutte le scorte dell'ingrediente. Va chiamata con il lock già acquisito
+func (inv *Inventory) scorteDi(nome string) []string {
+	var chiavi []string
+	for _, chiave := range ordinaChiavi(inv.ingredienti) {
+		if inv.ingredienti[chiave].Nome == nome {
+			chiavi = append(chiavi, chiave)
+		}
+	}
+	return chiavi
+}
+
+// scortaPiatto restituisce la scorta da cui il piatto preleva l'ingrediente: quella della sua
+// stazione se esiste, altrimenti la dispensa comune. Va chiamata con il lock già acquisito
+func (inv *Inventory) scortaPiatto(piatto Piatto, nome string) string {
+	chiave := chiaveScorta(stazionePiatto(piatto), nome)
+	if _, exists := inv.ingredienti[chiave]; exists {
+		return chiave
+	}
+	return nome
+}
+
+// fabbisogno calcola le scorte consumate da una porzione del piatto con le modifiche indicate:
+// la ricetta base, meno gli ingredienti rimossi, più le dosi delle aggiunte.
+// Va chiamata con il lock già acquisito
+func (inv *Inventory) fabbisogno(piatto Piatto, modifiche []models.Modifica) map[string]float64 {
+	dosi := make(map[string]float64, len(piatto.Ricetta))
+	for ingrediente, quantita := range piatto.Ricetta {
+		dosi[inv.scortaPiatto(piatto, ingrediente)] = quantita
+	}
+
+	for _, mod := range modifiche {
+		switch mod.Tipo {
+		case "-":
+			delete(dosi, inv.scortaPiatto(piatto, mod.Voce))
+		case "+":
+			if quantita, exists := piatto.Aggiunte[mod.Voce]; exists {
+				dosi[inv.scortaPiatto(piatto, mod.Voce)] += quantita
+			}
+		}
+	}
+
+	return dosi
+}
+
+// usaScorta indica se il piatto preleva dalla scorta indicata, con la ricetta o con un'aggiunta.
+// Va chiamata con il lock già acquisito
+func (inv *Inventory) usaScorta(piatto Piatto, chiave string) bool {
+	for _, dosi := range []map[string]float64{piatto.Ricetta, piatto.Aggiunte} {
+		for ingrediente := range dosi {
+			if inv.scortaPiatto(piatto, ingrediente) == chiave {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// verificaStazionePiatto controlla che la stazione del piatto esista e che il piatto non usi
+// ingredienti presenti solo nella scorta di altre stazioni. Va chiamata con il lock già acquisito
+func (inv *Inventory) verificaStazionePiatto(piatto Piatto) error {
+	stazione := stazionePiatto(piatto)
+	if _, exists := inv.stazioni[stazione]; !exists && stazione != StazionePredefinita {
+		return fmt.Errorf("la stazione '%s' del piatto '%s' non esiste", stazione, piatto.Nome)
+	}
+
+	for _, sezione := range []map[string]float64{piatto.Ricetta, piatto.Aggiunte} {
+		for _, ingrediente := range ordinaChiavi(sezione) {
+			if _, exists := inv.ingredienti[inv.scortaPiatto(piatto, ingrediente)]; exists {
+				continue
+			}
+			if altre := inv.scorteDi(ingrediente); len(altre) > 0 {
+				return fmt.Errorf("il piatto '%s' della stazione '%s' usa '%s', che è solo nella scorta di %s",
+					piatto.Nome, stazione, ingrediente, strings.Join(altre, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+// verificaStazioneIngrediente controlla che la stazione della scorta esista e che la scorta non
+// finisca a piatti di altre stazioni, che non hanno l'ingrediente né nella propria scorta né nella
+// dispensa comune. Va chiamata con il lock già acquisito
+func (inv *Inventory) verificaStazioneIngrediente(ingrediente Ingrediente) error {
+	if ingrediente.Stazione == "" {
+		return nil
+	}
+	if _, exists := inv.stazioni[ingrediente.Stazione]; !exists && ingrediente.Stazione != StazionePredefinita {
+		return fmt.Errorf("la stazione '%s' dell'ingrediente '%s' non esiste", ingrediente.Stazione, ingrediente.Nome)
+	}
+
+	for _, nome := range ordinaChiavi(inv.piatti) {
+		piatto := inv.piatti[nome]
+		if stazionePiatto(piatto) == ingrediente.Stazione {
+			continue
+		}
+		_, inRicetta := piatto.Ricetta[ingrediente.Nome]
+		_, inAggiunte := piatto.Aggiunte[ingrediente.Nome]
+		if !inRicetta && !inAggiunte {
+			continue
+		}
+		if _, exists := inv.ingredienti[inv.scortaPiatto(piatto, ingrediente.Nome)]; !exists {
+			return fmt.Errorf("l'ingrediente '%s' della stazione '%s' è usato dal piatto '%s' della stazione '%s', che non ne ha una scorta",
+				ingrediente.Nome, ingrediente.Stazione, nome, stazionePiatto(piatto))
+		}
+	}
+	return nil
+}
+
+// verificaIngredienti controlla che le scorte non scadute nel giorno indicato coprano le dosi richieste.
+// Va chiamata con il lock già acquisito
+func (inv *Inventory) verificaIngredienti(nomePiatto string, dosi map[string]float64, giorno time.Time) error {
+	for _, nome := range ordinaChiavi(dosi) {
+		ingrediente, exists := inv.ingredienti[nome]
+		if !exists {
+			return errors.NewIngredienteEsauritoError(nomePiatto, nome, "L'ingrediente non è presente in dispensa")
+		}
+		if ingrediente.Quantita < dosi[nome] {
+			return errors.NewIngredienteEsauritoError(
+				nomePiatto,
+				ingrediente.Nome,
+				fmt.Sprintf("Servono %g %s, ne restano %g", dosi[nome], ingrediente.Unita, ingrediente.Quantita),
+			)
+		}
+		if ingrediente.DisponibileAl(giorno) < dosi[nome] {
+			return erroreScaduti(nomePiatto, ingrediente, dosi[nome], giorno)
+		}
+	}
+
+	return nil
+}
+
+// ordinaChiavi restituisce le chiavi in ordine alfabetico, per avere errori e giornale deterministici
+func ordinaChiavi[V any](m map[string]V) []string {
+	chiavi := make([]string, 0, len(m))
+	for chiave := range m {
+		chiavi = append(chiavi, chiave)
+	}
+	sort.Strings(chiavi)
+	return chiavi
+}
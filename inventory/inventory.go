@@ -2,70 +2,242 @@ package inventory
 
 import (
 	"fmt"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/branila/restaurant-protocol/errors"
+	"github.com/branila/restaurant-protocol/models"
+	"github.com/branila/restaurant-protocol/money"
+)
+
+// Portate previste dal protocollo
+const (
+	PortataPrimo    = "PRIMO"
+	PortataSecondo  = "SECONDO"
+	PortataContorno = "CONTORNO"
 )
 
 type Piatto struct {
 	Nome                string
-	Disponibilita       int
-	ModificheConsentite map[string]bool // true = aggiungere, false = rimuovere
+	Portata             string
+	Stazione            string   // stazione che prepara il piatto; vuota = StazionePredefinita
+	AltrePortate        []string // portate aggiuntive in cui il piatto può essere ordinato
+	Disponibilita       float64  // porzioni intere; le varianti possono consumarne una frazione
+	Prezzo              money.Importo
+	Supplementi         map[string]money.Importo // voce aggiunta con "+" -> sovrapprezzo
+	ModificheConsentite map[string]bool          // true = aggiungere, false = rimuovere
+	Opzioni             map[string]Opzione
+	Varianti            map[string]Variante
+	Ricetta             map[string]float64 // ingrediente -> quantità consumata da una porzione
+	Aggiunte            map[string]float64 // voce aggiunta con "+" -> quantità dell'ingrediente omonimo
+	SogliaScortaBassa   *int               // nil = SogliaPredefinita, 0 = nessun avviso
+	Calendario          *Calendario        // nil = servito sempre
+	Allergeni           []string
+	Tag                 []string // tag dichiarati; vegano, vegetariano e senza glutine sono ricavati dalle categorie
+	Sospeso             bool     // "86": non servibile anche se restano porzioni
+	MotivoSospensione   string
 }
 
 type Inventory struct {
-	piatti map[string]Piatto
-	mu     sync.RWMutex
+	piatti      map[string]Piatto
+	ingredienti map[string]Ingrediente
+	registro    Registro            // se presente, riceve ogni movimento prima che venga applicato
+	storico     []Movimento         // tutti i movimenti applicati, in ordine
+	categorie   map[string][]string // voce -> categorie alimentari, vedi SetCategorie
+	stazioni    map[string]Stazione
+	fornitori   map[string]Fornitore
+	versioni    []VersioneMenu // versioni registrate del menu, dalla più vecchia
+	coperto     money.Importo
+	mu          sync.RWMutex
+
+	sottoscrizioni []sottoscrizione
+	prossimoID     int
+	muEventi       sync.Mutex
 }
 
 func New() *Inventory {
 	return &Inventory{
-		piatti: make(map[string]Piatto),
+		piatti:      make(map[string]Piatto),
+		ingredienti: make(map[string]Ingrediente),
+		categorie:   make(map[string][]string),
+		stazioni:    make(map[string]Stazione),
+		fornitori:   make(map[string]Fornitore),
 	}
 }
 
+// DefaultInventory restituisce il menu predefinito, usato quando non viene indicato un file.
+// I dati sono fissi: un errore nel caricarli è un errore di programmazione, quindi va in panic
 func DefaultInventory() *Inventory {
 	inv := New()
+	deve := func(err error) {
+		if err != nil {
+			panic(fmt.Sprintf("inventario predefinito non valido: %v", err))
+		}
+	}
+	inv.SetCoperto(money.Euro(2, 0))
+
+	// Stazioni della cucina
+	inv.SetStazione(Stazione{Nome: "pasta"})
+	inv.SetStazione(Stazione{Nome: "griglia"})
+	inv.SetStazione(Stazione{Nome: "insalate"})
+
+	// Fornitori a cui riordinare gli ingredienti sotto il livello di riferimento
+	inv.SetFornitore(Fornitore{Nome: "Pastificio Rossi", Telefono: "055 123456", Email: "ordini@pastificiorossi.it"})
+	inv.SetFornitore(Fornitore{Nome: "Ortofrutta Bianchi", Telefono: "055 654321"})
+	inv.SetFornitore(Fornitore{Nome: "Caseificio Verdi", Email: "vendite@caseificioverdi.it"})
+	inv.SetFornitore(Fornitore{Nome: "Macelleria Neri", Telefono: "055 112233"})
+
+	// Dispensa: ogni stazione ha la propria scorta, i formaggi sono in comune
+	for _, ingrediente := range []Ingrediente{
+		{Nome: "pasta", Quantita: 5000, Unita: UnitaGrammi, Stazione: "pasta", Par: 3000, Fornitore: "Pastificio Rossi", Confezione: 1000},
+		{Nome: "riso", Quantita: 2000, Unita: UnitaGrammi, Stazione: "pasta", Par: 1500, Fornitore: "Pastificio Rossi", Confezione: 1000},
+		{Nome: "pomodoro", Quantita: 3000, Unita: UnitaGrammi, Stazione: "pasta", Par: 2000, Fornitore: "Ortofrutta Bianchi"},
+		{Nome: "formaggio", Quantita: 1000, Unita: UnitaGrammi, Par: 500, Fornitore: "Caseificio Verdi"},
+		{Nome: "parmigiano", Quantita: 500, Unita: UnitaGrammi, Par: 300, Fornitore: "Caseificio Verdi"},
+		{Nome: "funghi", Quantita: 1000, Unita: UnitaGrammi, Stazione: "pasta", Par: 600, Fornitore: "Ortofrutta Bianchi"},
+		{Nome: "bistecca", Quantita: 8, Unita: UnitaPezzi, Stazione: "griglia", Par: 10, Fornitore: "Macelleria Neri"},
+		{Nome: "lattuga", Quantita: 2000, Unita: UnitaGrammi, Stazione: "insalate", Par: 1000, Fornitore: "Ortofrutta Bianchi"},
+		{Nome: "pomodorini", Quantita: 1000, Unita: UnitaGrammi, Stazione: "insalate", Par: 500, Fornitore: "Ortofrutta Bianchi"},
+	} {
+		deve(inv.SetIngrediente(ingrediente))
+	}
+
+	// Categorie alimentari, da cui si ricavano i piatti vegani, vegetariani e senza glutine
+	deve(inv.SetCategorie("pasta", CategoriaGlutine))
+	deve(inv.SetCategorie("formaggio", CategoriaLatticini))
+	deve(inv.SetCategorie("parmigiano", CategoriaLatticini))
+	deve(inv.SetCategorie("burro", CategoriaLatticini))
+	deve(inv.SetCategorie("bistecca", CategoriaCarne))
+	for _, voce := range []string{"riso", "pomodoro", "basilico", "funghi", "Salsa barbecue", "pepe", "sale",
+		"lattuga", "pomodorini", "aceto balsamico", "olio"} {
+		deve(inv.SetCategorie(voce, CategoriaNessuna))
+	}
 
 	// Primi piatti
-	inv.AddPiatto("pasta al pomodoro", 10, map[string]bool{
-		"formaggio": true,
-		"basilico":  false,
-		"pomodoro":  false,
-	})
+	deve(inv.SetPiatto(Piatto{
+		Nome:          "pasta al pomodoro",
+		Portata:       PortataPrimo,
+		Stazione:      "pasta",
+		Disponibilita: 10,
+		Prezzo:        money.Euro(8, 50),
+		ModificheConsentite: map[string]bool{
+			"formaggio": true,
+			"basilico":  false,
+			"pomodoro":  false,
+		},
+		Ricetta:     map[string]float64{"pasta": 100, "pomodoro": 80},
+		Aggiunte:    map[string]float64{"formaggio": 20},
+		Supplementi: map[string]money.Importo{"formaggio": money.Euro(1, 0)},
+		Varianti: map[string]Variante{
+			"mezza": {Nome: "mezza", PercentualePrezzo: 60, Porzioni: 0.5},
+			"bimbi": {Nome: "bimbi", PercentualePrezzo: 70, Porzioni: 0.75},
+		},
+		Allergeni: []string{"glutine"},
+	}))
 
-	inv.AddPiatto("risotto ai funghi", 5, map[string]bool{
-		"parmigiano": true,
-		"funghi":     false,
-		"burro":      false,
-	})
+	deve(inv.SetPiatto(Piatto{
+		Nome:          "risotto ai funghi",
+		Portata:       PortataPrimo,
+		Stazione:      "pasta",
+		Disponibilita: 5,
+		Prezzo:        money.Euro(12, 0),
+		ModificheConsentite: map[string]bool{
+			"parmigiano": true,
+			"funghi":     false,
+			"burro":      false,
+		},
+		Ricetta:     map[string]float64{"riso": 90, "funghi": 60},
+		Aggiunte:    map[string]float64{"parmigiano": 15},
+		Supplementi: map[string]money.Importo{"parmigiano": money.Euro(1, 50)},
+		Allergeni:   []string{"latte"},
+		Opzioni: map[string]Opzione{
+			"mantecatura": {Nome: "mantecatura", Valori: []string{"classica", "all'onda"}},
+		},
+		// Il risotto si prepara solo il venerdì
+		Calendario: &Calendario{Giorni: []time.Weekday{time.Friday}},
+	}))
 
 	// Secondi
-	inv.AddPiatto("Bistecca", 8, map[string]bool{
-		"Salsa barbecue": true,
-		"pepe":           true,
-		"sale":           false,
-	})
+	deve(inv.SetPiatto(Piatto{
+		Nome:          "Bistecca",
+		Portata:       PortataSecondo,
+		Stazione:      "griglia",
+		Disponibilita: 8,
+		Prezzo:        money.Euro(18, 0),
+		ModificheConsentite: map[string]bool{
+			"Salsa barbecue": true,
+			"pepe":           true,
+			"sale":           false,
+		},
+		Supplementi: map[string]money.Importo{"Salsa barbecue": money.Euro(0, 50)},
+		Ricetta:     map[string]float64{"bistecca": 1},
+		Opzioni: map[string]Opzione{
+			"cottura": {Nome: "cottura", Obbligatoria: true, Valori: []string{"al sangue", "media", "ben cotta"}},
+		},
+	}))
 
 	// Contorni
-	inv.AddPiatto("insalata", 15, map[string]bool{
-		"aceto balsamico": true,
-		"pomodorini":      true,
-		"olio":            false,
-	})
+	deve(inv.SetPiatto(Piatto{
+		Nome:          "insalata",
+		Portata:       PortataContorno,
+		Stazione:      "insalate",
+		Disponibilita: 15,
+		Prezzo:        money.Euro(4, 50),
+		ModificheConsentite: map[string]bool{
+			"aceto balsamico": true,
+			"pomodorini":      true,
+			"olio":            false,
+		},
+		Ricetta:  map[string]float64{"lattuga": 80},
+		Aggiunte: map[string]float64{"pomodorini": 40},
+	}))
 
 	return inv
 }
 
-func (inv *Inventory) AddPiatto(nome string, disponibilita int, modifiche map[string]bool) {
+// SetCoperto imposta il costo del coperto per persona
+func (inv *Inventory) SetCoperto(coperto money.Importo) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	inv.coperto = coperto
+}
+
+// Coperto restituisce il costo del coperto per persona
+func (inv *Inventory) Coperto() money.Importo {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	return inv.coperto
+}
+
+// SetPiatto inserisce o sostituisce un piatto con tutti i suoi attributi.
+// L'inserimento viene registrato nello storico con le porzioni iniziali
+func (inv *Inventory) SetPiatto(piatto Piatto) error {
 	inv.mu.Lock()
 	defer inv.mu.Unlock()
 
-	inv.piatti[nome] = Piatto{
+	if err := inv.verificaStazionePiatto(piatto); err != nil {
+		return err
+	}
+
+	movimenti := []Movimento{{Tipo: MovimentoInserimento, Piatto: piatto.Nome, Quantita: piatto.Disponibilita}}
+	if err := inv.registra(movimenti...); err != nil {
+		return err
+	}
+	inv.piatti[piatto.Nome] = piatto
+	inv.applica(movimenti[0])
+
+	return nil
+}
+
+func (inv *Inventory) AddPiatto(nome string, disponibilita float64, modifiche map[string]bool) error {
+	return inv.SetPiatto(Piatto{
 		Nome:                nome,
 		Disponibilita:       disponibilita,
 		ModificheConsentite: modifiche,
-	}
+	})
 }
 
 func (inv *Inventory) GetPiatto(nome string) (Piatto, bool) {
@@ -80,20 +252,53 @@ func (inv *Inventory) VerificaDisponibilita(nome string) error {
 	inv.mu.RLock()
 	defer inv.mu.RUnlock()
 
+	return inv.verificaDisponibilita(nome, time.Now())
+}
+
+// verificaDisponibilita controlla porzioni e ingredienti non scaduti nel giorno indicato.
+// Va chiamata con il lock già acquisito
+func (inv *Inventory) verificaDisponibilita(nome string, giorno time.Time) error {
 	piatto, exists := inv.piatti[nome]
 	if !exists {
-		return &errors.OrderError{
-			Code:    errors.ErrCodePiattoEsaurito,
-			Message: fmt.Sprintf("Il piatto '%s' non esiste nel menu", nome),
-			Details: "Consultare il menu aggiornato per verificare i piatti disponibili",
-		}
+		return errors.NewPiattoInesistenteError(nome)
+	}
+
+	if piatto.Sospeso {
+		return errors.NewPiattoSospesoError(nome, piatto.MotivoSospensione)
 	}
 
 	if piatto.Disponibilita <= 0 {
 		return errors.NewPiattoEsauritoError(nome, 0)
 	}
 
-	return nil
+	// Il piatto non è disponibile se manca uno degli ingredienti della ricetta base
+	return inv.verificaIngredienti(nome, inv.fabbisogno(piatto, nil), giorno)
+}
+
+// VerificaPortata controlla che il piatto possa essere ordinato nella portata indicata.
+// I piatti senza portata dichiarata sono ammessi in qualsiasi portata
+func (inv *Inventory) VerificaPortata(nomePiatto string, portata string) error {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	piatto, exists := inv.piatti[nomePiatto]
+	if !exists {
+		return errors.NewPiattoInesistenteError(nomePiatto)
+	}
+
+	if piatto.Portata == "" || piatto.Portata == portata || slices.Contains(piatto.AltrePortate, portata) {
+		return nil
+	}
+
+	return errors.NewPortataErrataError(nomePiatto, portata, piatto.Portate())
+}
+
+// Portate restituisce tutte le portate in cui il piatto può essere ordinato
+func (p Piatto) Portate() []string {
+	if p.Portata == "" {
+		return nil
+	}
+	return append([]string{p.Portata}, p.AltrePortate...)
 }
 
 func (inv *Inventory) VerificaModifica(nomePiatto string, tipoModifica string, voceModifica string) error {
@@ -102,11 +307,7 @@ func (inv *Inventory) VerificaModifica(nomePiatto string, tipoModifica string, v
 
 	piatto, exists := inv.piatti[nomePiatto]
 	if !exists {
-		return &errors.OrderError{
-			Code:    errors.ErrCodePiattoEsaurito,
-			Message: fmt.Sprintf("Il piatto '%s' non esiste nel menu", nomePiatto),
-			Details: "Consultare il menu aggiornato per verificare i piatti disponibili",
-		}
+		return errors.NewPiattoInesistenteError(nomePiatto)
 	}
 
 	isAggiunta := tipoModifica == "+"
@@ -136,29 +337,11 @@ func (inv *Inventory) VerificaModifica(nomePiatto string, tipoModifica string, v
 }
 
 func (inv *Inventory) DecrementaDisponibilita(nome string) error {
-	inv.mu.Lock()
-	defer inv.mu.Unlock()
-
-	piatto, exists := inv.piatti[nome]
-	if !exists {
-		return &errors.OrderError{
-			Code:    errors.ErrCodePiattoEsaurito,
-			Message: fmt.Sprintf("Il piatto '%s' non esiste nel menu", nome),
-			Details: "Consultare il menu aggiornato per verificare i piatti disponibili",
-		}
-	}
-
-	if piatto.Disponibilita <= 0 {
-		return errors.NewPiattoEsauritoError(nome, 0)
-	}
-
-	piatto.Disponibilita--
-	inv.piatti[nome] = piatto
-
-	// Avvisa se stiamo per esaurire il piatto
-	if piatto.Disponibilita <= 3 {
-		fmt.Printf("Attenzione: rimangono solo %d porzioni di %s\n", piatto.Disponibilita, nome)
-	}
+	return inv.DecrementaConModifiche(nome, nil)
+}
 
-	return nil
+// DecrementaConModifiche scala una porzione del piatto e gli ingredienti della ricetta,
+// tenendo conto degli ingredienti rimossi e aggiunti con le modifiche
+func (inv *Inventory) DecrementaConModifiche(nome string, modifiche []models.Modifica) error {
+	return inv.Consuma([]Richiesta{{Piatto: nome, Modifiche: modifiche}})
 }
@@ -0,0 +1,803 @@
+package inventory
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/branila/restaurant-protocol/money"
+	"github.com/branila/restaurant-protocol/units"
+	"gopkg.in/yaml.v2"
+)
+
+// fileMenu è lo schema del file che descrive il menu e l'inventario
+type fileMenu struct {
+	Coperto     *prezzoFile         `yaml:"coperto" json:"coperto"`
+	Stazioni    []fileStazione      `yaml:"stazioni" json:"stazioni"`
+	Fornitori   []fileFornitore     `yaml:"fornitori" json:"fornitori"`
+	Ingredienti []fileIngrediente   `yaml:"ingredienti" json:"ingredienti"`
+	Categorie   map[string][]string `yaml:"categorie" json:"categorie"` // categoria alimentare -> voci che la contengono
+	Piatti      []filePiatto        `yaml:"piatti" json:"piatti"`
+	Versioni    []fileVersione      `yaml:"versioni" json:"versioni"` // menu in vigore in periodi diversi
+}
+
+type fileVersione struct {
+	Nome   string       `yaml:"nome" json:"nome"`
+	Dal    string       `yaml:"dal" json:"dal"`       // DD/MM/YYYY
+	Piatti []filePiatto `yaml:"piatti" json:"piatti"` // se mancano, i piatti del menu; le porzioni sono facoltative
+}
+
+type fileStazione struct {
+	Nome      string `yaml:"nome" json:"nome"`
+	Stampante string `yaml:"stampante" json:"stampante"` // file su cui stampare i ticket della stazione
+}
+
+type fileFornitore struct {
+	Nome     string `yaml:"nome" json:"nome"`
+	Telefono string `yaml:"telefono" json:"telefono"`
+	Email    string `yaml:"email" json:"email"`
+}
+
+type fileIngrediente struct {
+	Nome     string      `yaml:"nome" json:"nome"`
+	Quantita *float64    `yaml:"quantita" json:"quantita"` // se mancano, la somma dei lotti
+	Lotti    []fileLotto `yaml:"lotti" json:"lotti"`
+	Unita    string      `yaml:"unita" json:"unita"`
+	Stazione string      `yaml:"stazione" json:"stazione"` // se manca, l'ingrediente è nella dispensa comune
+
+	Par        float64 `yaml:"par" json:"par"` // scorta sotto cui riordinare
+	Fornitore  string  `yaml:"fornitore" json:"fornitore"`
+	Confezione float64 `yaml:"confezione" json:"confezione"` // si ordinano multipli di questa quantità
+}
+
+type fileLotto struct {
+	Codice   string  `yaml:"codice" json:"codice"`
+	Quantita float64 `yaml:"quantita" json:"quantita"`
+	Scadenza string  `yaml:"scadenza" json:"scadenza"` // DD/MM/YYYY
+}
+
+type filePiatto struct {
+	Nome        string                  `yaml:"nome" json:"nome"`
+	Portata     string                  `yaml:"portata" json:"portata"`
+	Stazione    string                  `yaml:"stazione" json:"stazione"`           // se manca, la stazione predefinita
+	Altre       []string                `yaml:"altre_portate" json:"altre_portate"` // portate aggiuntive ammesse
+	Porzioni    *float64                `yaml:"porzioni" json:"porzioni"`
+	Prezzo      *prezzoFile             `yaml:"prezzo" json:"prezzo"`
+	Modifiche   map[string]string       `yaml:"modifiche" json:"modifiche"` // "+" = aggiungere, "-" = rimuovere
+	Opzioni     []fileOpzione           `yaml:"opzioni" json:"opzioni"`
+	Ricetta     map[string]quantitaFile `yaml:"ricetta" json:"ricetta"`         // ingrediente -> quantità per porzione
+	Aggiunte    map[string]quantitaFile `yaml:"aggiunte" json:"aggiunte"`       // voce aggiunta -> quantità consumata
+	Supplementi map[string]prezzoFile   `yaml:"supplementi" json:"supplementi"` // voce aggiunta -> sovrapprezzo
+	Soglia      *int                    `yaml:"soglia" json:"soglia"`           // porzioni sotto cui il piatto è in esaurimento, 0 = nessun avviso
+	Disponibile *fileCalendario         `yaml:"disponibile" json:"disponibile"` // giorni e orari in cui il piatto è servito
+	Varianti    []fileVariante          `yaml:"varianti" json:"varianti"`       // es. mezza porzione, porzione bimbi
+	Allergeni   []string                `yaml:"allergeni" json:"allergeni"`
+	Tag         []string                `yaml:"tag" json:"tag"` // es. [piccante]; vegano, vegetariano e senza glutine si ricavano dalle categorie
+}
+
+type fileCalendario struct {
+	Giorni  []string      `yaml:"giorni" json:"giorni"`   // es. [venerdi]
+	Orari   []string      `yaml:"orari" json:"orari"`     // es. ["12:00-15:00"]
+	Periodi []filePeriodo `yaml:"periodi" json:"periodi"` // es. [{dal: 01/12/2025, al: 24/12/2025}]
+}
+
+type filePeriodo struct {
+	Dal string `yaml:"dal" json:"dal"`
+	Al  string `yaml:"al" json:"al"`
+}
+
+type fileOpzione struct {
+	Nome         string   `yaml:"nome" json:"nome"`
+	Obbligatoria bool     `yaml:"obbligatoria" json:"obbligatoria"`
+	Valori       []string `yaml:"valori" json:"valori"`
+}
+
+type fileVariante struct {
+	Nome     string   `yaml:"nome" json:"nome"`
+	Prezzo   *int     `yaml:"prezzo_percentuale" json:"prezzo_percentuale"` // percentuale del prezzo del piatto
+	Porzioni *float64 `yaml:"porzioni" json:"porzioni"`                     // porzioni scalate dall'inventario
+}
+
+// quantitaFile accetta una dose come numero, nell'unità dell'ingrediente, oppure
+// come testo con la sua unità (es. "100 g" o "0.1 kg")
+type quantitaFile string
+
+func (q *quantitaFile) UnmarshalJSON(data []byte) error {
+	var p prezzoFile
+	if err := p.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	*q = quantitaFile(p)
+	return nil
+}
+
+func (q *quantitaFile) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var testo string
+	if err := unmarshal(&testo); err != nil {
+		return err
+	}
+	*q = quantitaFile(testo)
+	return nil
+}
+
+// inUnita converte la dose nell'unità in cui è tenuta la scorta dell'ingrediente
+func (q quantitaFile) inUnita(unita units.Unita) (float64, error) {
+	if valore, err := strconv.ParseFloat(strings.TrimSpace(string(q)), 64); err == nil {
+		return valore, nil
+	}
+	quantita, err := units.Parse(string(q))
+	if err != nil {
+		return 0, err
+	}
+	convertita, err := quantita.In(unita)
+	if err != nil {
+		return 0, fmt.Errorf("la scorta è in %s: %w", unita, err)
+	}
+	return convertita.Valore, nil
+}
+
+// prezzoFile accetta il prezzo sia come numero sia come stringa
+type prezzoFile string
+
+func (p *prezzoFile) UnmarshalJSON(data []byte) error {
+	testo := string(data)
+	if strings.HasPrefix(testo, `"`) {
+		unquoted, err := strconv.Unquote(testo)
+		if err != nil {
+			return err
+		}
+		testo = unquoted
+	}
+	*p = prezzoFile(testo)
+	return nil
+}
+
+func (p *prezzoFile) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var testo string
+	if err := unmarshal(&testo); err != nil {
+		return err
+	}
+	*p = prezzoFile(testo)
+	return nil
+}
+
+// LoadFromFile carica il menu e le porzioni disponibili da un file YAML o JSON,
+// oppure i soli piatti da un file CSV
+func LoadFromFile(path string) (*Inventory, error) {
+	if strings.ToLower(filepath.Ext(path)) == ".csv" {
+		return LoadFromCSV(path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("errore nella lettura del menu: %w", err)
+	}
+
+	var menu fileMenu
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.UnmarshalStrict(data, &menu); err != nil {
+			return nil, fmt.Errorf("%s: YAML non valido: %w", path, err)
+		}
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&menu); err != nil {
+			return nil, fmt.Errorf("%s: JSON non valido: %w", path, descriviErroreJSON(data, err))
+		}
+	default:
+		return nil, fmt.Errorf("%s: estensione non supportata, usare .yaml, .yml, .json o .csv", path)
+	}
+
+	stazioni, ingredienti, piatti, versioni, err := menu.valida()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	categorie, err := menu.validaCategorie()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	fornitori, err := menu.validaFornitori()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	inv := New()
+	if menu.Coperto != nil {
+		coperto, err := parsePrezzo(string(*menu.Coperto))
+		if err != nil {
+			return nil, fmt.Errorf("%s: coperto: %w", path, err)
+		}
+		inv.SetCoperto(coperto)
+	}
+	for _, stazione := range stazioni {
+		inv.SetStazione(stazione)
+	}
+	for _, fornitore := range fornitori {
+		inv.SetFornitore(fornitore)
+	}
+	for _, ingrediente := range ingredienti {
+		if err := inv.SetIngrediente(ingrediente); err != nil {
+			return nil, err
+		}
+	}
+	for _, piatto := range piatti {
+		if err := inv.SetPiatto(piatto); err != nil {
+			return nil, err
+		}
+	}
+	for voce, categorieVoce := range categorie {
+		if err := inv.SetCategorie(voce, categorieVoce...); err != nil {
+			return nil, fmt.Errorf("%s: categorie: %w", path, err)
+		}
+	}
+	for i, versione := range versioni {
+		if err := inv.SetVersione(versione); err != nil {
+			return nil, fmt.Errorf("%s: versioni[%d]: %w", path, i, err)
+		}
+	}
+
+	return inv, nil
+}
+
+// validaCategorie converte la sezione categorie (categoria -> voci) nelle categorie di ogni voce
+func (m fileMenu) validaCategorie() (map[string][]string, error) {
+	categorie := make(map[string][]string)
+
+	for _, categoria := range ordinaChiavi(m.Categorie) {
+		if !CategoriaValida(categoria) {
+			return nil, fmt.Errorf("categorie.%s: categoria non valida, usare carne, pesce, latticini, uova, glutine o nessuna", categoria)
+		}
+		for i, voce := range m.Categorie[categoria] {
+			if strings.TrimSpace(voce) == "" {
+				return nil, fmt.Errorf("categorie.%s[%d]: valore vuoto", categoria, i)
+			}
+			categorie[voce] = append(categorie[voce], categoria)
+		}
+	}
+
+	return categorie, nil
+}
+
+// validaFornitori controlla la sezione fornitori e che gli ingredienti citino solo fornitori dichiarati
+func (m fileMenu) validaFornitori() ([]Fornitore, error) {
+	fornitori := make([]Fornitore, 0, len(m.Fornitori))
+	dichiarati := make(map[string]bool, len(m.Fornitori))
+
+	for i, ff := range m.Fornitori {
+		if strings.TrimSpace(ff.Nome) == "" {
+			return nil, fmt.Errorf("fornitori[%d]: il campo 'nome' è obbligatorio", i)
+		}
+		if dichiarati[ff.Nome] {
+			return nil, fmt.Errorf("fornitori[%d] (%q): fornitore duplicato", i, ff.Nome)
+		}
+		dichiarati[ff.Nome] = true
+		fornitori = append(fornitori, Fornitore{Nome: ff.Nome, Telefono: ff.Telefono, Email: ff.Email})
+	}
+
+	for i, fi := range m.Ingredienti {
+		if fi.Fornitore != "" && !dichiarati[fi.Fornitore] {
+			return nil, fmt.Errorf("ingredienti[%d] (%q): fornitore %q non presente nella sezione 'fornitori'", i, fi.Nome, fi.Fornitore)
+		}
+	}
+
+	return fornitori, nil
+}
+
+// valida controlla lo schema del menu e lo converte negli ingredienti, nei piatti e nelle versioni dell'inventario
+func (m fileMenu) valida() ([]Stazione, []Ingrediente, []Piatto, []VersioneMenu, error) {
+	if len(m.Piatti) == 0 {
+		return nil, nil, nil, nil, fmt.Errorf("il menu non contiene piatti")
+	}
+
+	stazioni := make([]Stazione, 0, len(m.Stazioni))
+	dichiarate := map[string]bool{StazionePredefinita: true}
+
+	for i, fs := range m.Stazioni {
+		if strings.TrimSpace(fs.Nome) == "" {
+			return nil, nil, nil, nil, fmt.Errorf("stazioni[%d]: il campo 'nome' è obbligatorio", i)
+		}
+		if dichiarate[fs.Nome] && fs.Nome != StazionePredefinita {
+			return nil, nil, nil, nil, fmt.Errorf("stazioni[%d] (%q): stazione duplicata", i, fs.Nome)
+		}
+		dichiarate[fs.Nome] = true
+
+		stazioni = append(stazioni, Stazione{Nome: fs.Nome, Stampante: fs.Stampante})
+	}
+
+	ingredienti := make([]Ingrediente, 0, len(m.Ingredienti))
+	dispensa := make(map[string]units.Unita, len(m.Ingredienti)) // ingrediente -> unità della scorta
+	stazioniIngrediente := make(map[string][]string, len(m.Ingredienti))
+
+	for i, fi := range m.Ingredienti {
+		campo := fmt.Sprintf("ingredienti[%d]", i)
+		if fi.Nome != "" {
+			campo = fmt.Sprintf("ingredienti[%d] (%q)", i, fi.Nome)
+		}
+
+		ingrediente, err := fi.valida()
+		if err != nil {
+			return nil, nil, nil, nil, fmt.Errorf("%s: %w", campo, err)
+		}
+		if slices.Contains(stazioniIngrediente[ingrediente.Nome], ingrediente.Stazione) {
+			return nil, nil, nil, nil, fmt.Errorf("%s: ingrediente duplicato nella stessa scorta", campo)
+		}
+		if ingrediente.Stazione != "" && !dichiarate[ingrediente.Stazione] {
+			return nil, nil, nil, nil, fmt.Errorf("%s: stazione %q non presente nella sezione 'stazioni'", campo, ingrediente.Stazione)
+		}
+		unita := units.Unita(ingrediente.Unita).Base()
+		if altra, exists := dispensa[ingrediente.Nome]; exists && altra != unita {
+			return nil, nil, nil, nil, fmt.Errorf("%s: unità %s diversa da quella delle altre scorte (%s)", campo, unita, altra)
+		}
+		dispensa[ingrediente.Nome] = unita
+		stazioniIngrediente[ingrediente.Nome] = append(stazioniIngrediente[ingrediente.Nome], ingrediente.Stazione)
+
+		ingredienti = append(ingredienti, ingrediente)
+	}
+
+	piatti, err := validaPiatti("piatti", m.Piatti, dispensa, dichiarate, stazioniIngrediente)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	versioni := make([]VersioneMenu, 0, len(m.Versioni))
+	date := make(map[string]bool, len(m.Versioni))
+	for i, fv := range m.Versioni {
+		campo := fmt.Sprintf("versioni[%d]", i)
+		if fv.Nome != "" {
+			campo = fmt.Sprintf("versioni[%d] (%q)", i, fv.Nome)
+		}
+
+		dal, err := time.Parse(FormatoData, fv.Dal)
+		if err != nil {
+			return nil, nil, nil, nil, fmt.Errorf("%s: data %q non valida, usare il formato DD/MM/YYYY", campo, fv.Dal)
+		}
+		if date[fv.Dal] {
+			return nil, nil, nil, nil, fmt.Errorf("%s: esiste già una versione in vigore dal %s", campo, fv.Dal)
+		}
+		date[fv.Dal] = true
+
+		piattiVersione := piatti
+		if len(fv.Piatti) > 0 {
+			// Le porzioni di una versione non vengono usate: le disponibilità sono quelle del menu
+			filePiatti := slices.Clone(fv.Piatti)
+			for j := range filePiatti {
+				if filePiatti[j].Porzioni == nil {
+					filePiatti[j].Porzioni = new(float64)
+				}
+			}
+			piattiVersione, err = validaPiatti(campo+".piatti", filePiatti, dispensa, dichiarate, stazioniIngrediente)
+			if err != nil {
+				return nil, nil, nil, nil, err
+			}
+		}
+
+		versione := VersioneMenu{Nome: fv.Nome, Dal: dal, Piatti: make(map[string]Piatto, len(piattiVersione))}
+		for _, piatto := range piattiVersione {
+			versione.Piatti[piatto.Nome] = piatto
+		}
+		versioni = append(versioni, versione)
+	}
+
+	return stazioni, ingredienti, piatti, versioni, nil
+}
+
+// validaPiatti converte i piatti di una sezione del menu, controllando ingredienti, stazioni e duplicati
+func validaPiatti(sezione string, filePiatti []filePiatto, dispensa map[string]units.Unita, dichiarate map[string]bool, stazioniIngrediente map[string][]string) ([]Piatto, error) {
+	piatti := make([]Piatto, 0, len(filePiatti))
+	visti := make(map[string]bool, len(filePiatti))
+
+	for i, fp := range filePiatti {
+		campo := fmt.Sprintf("%s[%d]", sezione, i)
+		if fp.Nome != "" {
+			campo = fmt.Sprintf("%s[%d] (%q)", sezione, i, fp.Nome)
+		}
+
+		piatto, err := fp.valida(dispensa)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", campo, err)
+		}
+		if err := validaStazionePiatto(piatto, dichiarate, stazioniIngrediente); err != nil {
+			return nil, fmt.Errorf("%s: %w", campo, err)
+		}
+
+		if visti[piatto.Nome] {
+			return nil, fmt.Errorf("%s: piatto duplicato", campo)
+		}
+		visti[piatto.Nome] = true
+
+		piatti = append(piatti, piatto)
+	}
+
+	return piatti, nil
+}
+
+// validaStazionePiatto controlla che la stazione del piatto sia dichiarata e che
+// gli ingredienti della ricetta e delle aggiunte siano nella sua scorta o nella dispensa comune
+func validaStazionePiatto(piatto Piatto, dichiarate map[string]bool, stazioniIngrediente map[string][]string) error {
+	if !dichiarate[stazionePiatto(piatto)] {
+		return fmt.Errorf("stazione %q non presente nella sezione 'stazioni'", piatto.Stazione)
+	}
+
+	for _, sezione := range []struct {
+		nome string
+		dosi map[string]float64
+	}{{"ricetta", piatto.Ricetta}, {"aggiunte", piatto.Aggiunte}} {
+		for _, ingrediente := range ordinaChiavi(sezione.dosi) {
+			stazioni := stazioniIngrediente[ingrediente]
+			if len(stazioni) == 0 || slices.Contains(stazioni, "") || slices.Contains(stazioni, stazionePiatto(piatto)) {
+				continue
+			}
+			return fmt.Errorf("%s.%s: l'ingrediente è solo nella scorta delle stazioni %s, non di %q",
+				sezione.nome, ingrediente, strings.Join(stazioni, ", "), stazionePiatto(piatto))
+		}
+	}
+	return nil
+}
+
+func (fi fileIngrediente) valida() (Ingrediente, error) {
+	if strings.TrimSpace(fi.Nome) == "" {
+		return Ingrediente{}, fmt.Errorf("il campo 'nome' è obbligatorio")
+	}
+
+	var lotti []Lotto
+	totaleLotti := 0.0
+	for i, fl := range fi.Lotti {
+		lotto, err := fl.valida()
+		if err != nil {
+			return Ingrediente{}, fmt.Errorf("lotti[%d]: %w", i, err)
+		}
+		if slices.ContainsFunc(lotti, func(l Lotto) bool { return l.Codice == lotto.Codice }) {
+			return Ingrediente{}, fmt.Errorf("lotti[%d]: lotto %q duplicato", i, lotto.Codice)
+		}
+		lotti = append(lotti, lotto)
+		totaleLotti += lotto.Quantita
+	}
+
+	if fi.Quantita == nil && len(lotti) == 0 {
+		return Ingrediente{}, fmt.Errorf("il campo 'quantita' è obbligatorio")
+	}
+	quantita := totaleLotti
+	if fi.Quantita != nil {
+		quantita = *fi.Quantita
+	}
+	if quantita < 0 {
+		return Ingrediente{}, fmt.Errorf("quantita non può essere negativa (%g)", quantita)
+	}
+	if quantita < totaleLotti {
+		return Ingrediente{}, fmt.Errorf("quantita (%g) è inferiore alla somma dei lotti (%g)", quantita, totaleLotti)
+	}
+
+	unita, err := units.ParseUnita(fi.Unita)
+	if err != nil {
+		return Ingrediente{}, err
+	}
+
+	if fi.Par < 0 {
+		return Ingrediente{}, fmt.Errorf("par non può essere negativo (%g)", fi.Par)
+	}
+	if fi.Confezione < 0 {
+		return Ingrediente{}, fmt.Errorf("confezione non può essere negativa (%g)", fi.Confezione)
+	}
+
+	return Ingrediente{
+		Nome:       fi.Nome,
+		Quantita:   quantita,
+		Unita:      string(unita),
+		Lotti:      lotti,
+		Stazione:   fi.Stazione,
+		Par:        fi.Par,
+		Fornitore:  fi.Fornitore,
+		Confezione: fi.Confezione,
+	}, nil
+}
+
+func (fl fileLotto) valida() (Lotto, error) {
+	if strings.TrimSpace(fl.Codice) == "" {
+		return Lotto{}, fmt.Errorf("il campo 'codice' è obbligatorio")
+	}
+	if fl.Quantita <= 0 {
+		return Lotto{}, fmt.Errorf("quantita deve essere positiva (%g)", fl.Quantita)
+	}
+	scadenza, err := time.Parse(FormatoData, fl.Scadenza)
+	if err != nil {
+		return Lotto{}, fmt.Errorf("scadenza %q non valida, usare il formato DD/MM/YYYY", fl.Scadenza)
+	}
+
+	return Lotto{Codice: fl.Codice, Quantita: fl.Quantita, Scadenza: scadenza}, nil
+}
+
+func (fp filePiatto) valida(dispensa map[string]units.Unita) (Piatto, error) {
+	if strings.TrimSpace(fp.Nome) == "" {
+		return Piatto{}, fmt.Errorf("il campo 'nome' è obbligatorio")
+	}
+	if strings.Contains(fp.Nome, `"`) {
+		return Piatto{}, fmt.Errorf("il campo 'nome' non può contenere virgolette")
+	}
+
+	if fp.Portata == "" {
+		return Piatto{}, fmt.Errorf("il campo 'portata' è obbligatorio (PRIMO, SECONDO o CONTORNO)")
+	}
+	if !portataValida(fp.Portata) {
+		return Piatto{}, fmt.Errorf("portata %q non valida, usare PRIMO, SECONDO o CONTORNO", fp.Portata)
+	}
+	for j, portata := range fp.Altre {
+		if !portataValida(portata) {
+			return Piatto{}, fmt.Errorf("altre_portate[%d]: portata %q non valida, usare PRIMO, SECONDO o CONTORNO", j, portata)
+		}
+		if portata == fp.Portata {
+			return Piatto{}, fmt.Errorf("altre_portate[%d]: %s è già la portata principale", j, portata)
+		}
+	}
+
+	if fp.Porzioni == nil {
+		return Piatto{}, fmt.Errorf("il campo 'porzioni' è obbligatorio")
+	}
+	if *fp.Porzioni < 0 {
+		return Piatto{}, fmt.Errorf("porzioni non può essere negativo (%g)", *fp.Porzioni)
+	}
+
+	if fp.Prezzo == nil {
+		return Piatto{}, fmt.Errorf("il campo 'prezzo' è obbligatorio")
+	}
+	prezzo, err := parsePrezzo(string(*fp.Prezzo))
+	if err != nil {
+		return Piatto{}, err
+	}
+
+	modifiche := make(map[string]bool, len(fp.Modifiche))
+	for voce, tipo := range fp.Modifiche {
+		switch tipo {
+		case "+":
+			modifiche[voce] = true
+		case "-":
+			modifiche[voce] = false
+		default:
+			return Piatto{}, fmt.Errorf("modifiche.%s: valore %q non valido, usare \"+\" o \"-\"", voce, tipo)
+		}
+	}
+
+	var opzioni map[string]Opzione
+	for j, fo := range fp.Opzioni {
+		if fo.Nome == "" {
+			return Piatto{}, fmt.Errorf("opzioni[%d]: il campo 'nome' è obbligatorio", j)
+		}
+		if opzioni == nil {
+			opzioni = make(map[string]Opzione, len(fp.Opzioni))
+		}
+		if _, duplicata := opzioni[fo.Nome]; duplicata {
+			return Piatto{}, fmt.Errorf("opzioni[%d]: opzione %q duplicata", j, fo.Nome)
+		}
+		opzioni[fo.Nome] = Opzione{
+			Nome:         fo.Nome,
+			Obbligatoria: fo.Obbligatoria,
+			Valori:       fo.Valori,
+		}
+	}
+
+	if fp.Soglia != nil && *fp.Soglia < 0 {
+		return Piatto{}, fmt.Errorf("soglia non può essere negativa (%d)", *fp.Soglia)
+	}
+
+	ricetta, err := dosiFile("ricetta", fp.Ricetta, dispensa)
+	if err != nil {
+		return Piatto{}, err
+	}
+
+	var calendario *Calendario
+	if fp.Disponibile != nil {
+		c, err := fp.Disponibile.valida()
+		if err != nil {
+			return Piatto{}, fmt.Errorf("disponibile.%w", err)
+		}
+		calendario = &c
+	}
+
+	var supplementi map[string]money.Importo
+	for voce, testo := range fp.Supplementi {
+		if !modifiche[voce] {
+			return Piatto{}, fmt.Errorf("supplementi.%s: la voce deve essere una modifica consentita con \"+\"", voce)
+		}
+		supplemento, err := parsePrezzo(string(testo))
+		if err != nil {
+			return Piatto{}, fmt.Errorf("supplementi.%s: %w", voce, err)
+		}
+		if supplementi == nil {
+			supplementi = make(map[string]money.Importo, len(fp.Supplementi))
+		}
+		supplementi[voce] = supplemento
+	}
+
+	for voce := range fp.Aggiunte {
+		if !modifiche[voce] {
+			return Piatto{}, fmt.Errorf("aggiunte.%s: la voce deve essere una modifica consentita con \"+\"", voce)
+		}
+	}
+	aggiunte, err := dosiFile("aggiunte", fp.Aggiunte, dispensa)
+	if err != nil {
+		return Piatto{}, err
+	}
+
+	var varianti map[string]Variante
+	for i, fv := range fp.Varianti {
+		variante, err := fv.valida()
+		if err != nil {
+			return Piatto{}, fmt.Errorf("varianti[%d]: %w", i, err)
+		}
+		if _, exists := varianti[variante.Nome]; exists {
+			return Piatto{}, fmt.Errorf("varianti[%d]: variante %q duplicata", i, variante.Nome)
+		}
+		if varianti == nil {
+			varianti = make(map[string]Variante, len(fp.Varianti))
+		}
+		varianti[variante.Nome] = variante
+	}
+
+	for i, allergene := range fp.Allergeni {
+		if strings.TrimSpace(allergene) == "" {
+			return Piatto{}, fmt.Errorf("allergeni[%d]: valore vuoto", i)
+		}
+	}
+	for i, tag := range fp.Tag {
+		if strings.TrimSpace(tag) == "" {
+			return Piatto{}, fmt.Errorf("tag[%d]: valore vuoto", i)
+		}
+		if tagRicavato(tag) {
+			return Piatto{}, fmt.Errorf("tag[%d]: %q viene ricavato dalla sezione categorie e non va dichiarato", i, tag)
+		}
+	}
+
+	return Piatto{
+		Nome:                fp.Nome,
+		Portata:             fp.Portata,
+		Stazione:            fp.Stazione,
+		AltrePortate:        fp.Altre,
+		Disponibilita:       *fp.Porzioni,
+		Prezzo:              prezzo,
+		Supplementi:         supplementi,
+		ModificheConsentite: modifiche,
+		Opzioni:             opzioni,
+		Varianti:            varianti,
+		Ricetta:             ricetta,
+		Aggiunte:            aggiunte,
+		SogliaScortaBassa:   fp.Soglia,
+		Calendario:          calendario,
+		Allergeni:           fp.Allergeni,
+		Tag:                 fp.Tag,
+	}, nil
+}
+
+// dosiFile converte le dosi di una sezione del piatto nelle unità delle scorte
+func dosiFile(sezione string, dosi map[string]quantitaFile, dispensa map[string]units.Unita) (map[string]float64, error) {
+	if dosi == nil {
+		return nil, nil
+	}
+
+	convertite := make(map[string]float64, len(dosi))
+	for _, ingrediente := range ordinaChiavi(dosi) {
+		unita, exists := dispensa[ingrediente]
+		if !exists {
+			return nil, fmt.Errorf("%s.%s: ingrediente non presente nella sezione 'ingredienti'", sezione, ingrediente)
+		}
+		quantita, err := dosi[ingrediente].inUnita(unita)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", sezione, ingrediente, err)
+		}
+		if quantita <= 0 {
+			return nil, fmt.Errorf("%s.%s: la quantità deve essere positiva", sezione, ingrediente)
+		}
+		convertite[ingrediente] = quantita
+	}
+	return convertite, nil
+}
+
+func (fv fileVariante) valida() (Variante, error) {
+	if strings.TrimSpace(fv.Nome) == "" {
+		return Variante{}, fmt.Errorf("il campo 'nome' è obbligatorio")
+	}
+	if strings.ContainsAny(fv.Nome, `"[]`) {
+		return Variante{}, fmt.Errorf("il campo 'nome' non può contenere virgolette o parentesi quadre")
+	}
+	if fv.Prezzo == nil {
+		return Variante{}, fmt.Errorf("il campo 'prezzo_percentuale' è obbligatorio")
+	}
+	if *fv.Prezzo <= 0 {
+		return Variante{}, fmt.Errorf("prezzo_percentuale deve essere positivo (%d)", *fv.Prezzo)
+	}
+	if fv.Porzioni == nil {
+		return Variante{}, fmt.Errorf("il campo 'porzioni' è obbligatorio")
+	}
+	if *fv.Porzioni <= 0 {
+		return Variante{}, fmt.Errorf("porzioni deve essere positivo (%g)", *fv.Porzioni)
+	}
+
+	return Variante{Nome: fv.Nome, PercentualePrezzo: *fv.Prezzo, Porzioni: *fv.Porzioni}, nil
+}
+
+func (fc fileCalendario) valida() (Calendario, error) {
+	var calendario Calendario
+
+	for i, nome := range fc.Giorni {
+		giorno, err := ParseGiorno(nome)
+		if err != nil {
+			return Calendario{}, fmt.Errorf("giorni[%d]: %w", i, err)
+		}
+		calendario.Giorni = append(calendario.Giorni, giorno)
+	}
+
+	for i, testo := range fc.Orari {
+		fascia, err := ParseFascia(testo)
+		if err != nil {
+			return Calendario{}, fmt.Errorf("orari[%d]: %w", i, err)
+		}
+		calendario.Fasce = append(calendario.Fasce, fascia)
+	}
+
+	for i, fp := range fc.Periodi {
+		dal, err := time.Parse(FormatoData, fp.Dal)
+		if err != nil {
+			return Calendario{}, fmt.Errorf("periodi[%d].dal: data %q non valida, usare il formato DD/MM/YYYY", i, fp.Dal)
+		}
+		al, err := time.Parse(FormatoData, fp.Al)
+		if err != nil {
+			return Calendario{}, fmt.Errorf("periodi[%d].al: data %q non valida, usare il formato DD/MM/YYYY", i, fp.Al)
+		}
+		if al.Before(dal) {
+			return Calendario{}, fmt.Errorf("periodi[%d]: la data di fine precede quella di inizio", i)
+		}
+		calendario.Periodi = append(calendario.Periodi, Periodo{Dal: dal, Al: al})
+	}
+
+	return calendario, nil
+}
+
+func portataValida(portata string) bool {
+	switch portata {
+	case PortataPrimo, PortataSecondo, PortataContorno:
+		return true
+	}
+	return false
+}
+
+// parsePrezzo converte un prezzo in euro (es. "8.50"), che non può essere negativo
+func parsePrezzo(testo string) (money.Importo, error) {
+	prezzo, err := money.Parse(testo)
+	if err != nil {
+		return 0, fmt.Errorf("prezzo %q non valido, usare il formato 8.50", testo)
+	}
+	if prezzo < 0 {
+		return 0, fmt.Errorf("prezzo %q non può essere negativo", testo)
+	}
+	return prezzo, nil
+}
+
+// descriviErroreJSON aggiunge riga e colonna agli errori di sintassi JSON
+func descriviErroreJSON(data []byte, err error) error {
+	var offset int64
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		offset = e.Offset
+	case *json.UnmarshalTypeError:
+		offset = e.Offset
+	default:
+		return err
+	}
+
+	riga, colonna := 1, 1
+	for _, b := range data[:min(int(offset), len(data))] {
+		if b == '\n' {
+			riga++
+			colonna = 1
+		} else {
+			colonna++
+		}
+	}
+
+	return fmt.Errorf("riga %d, colonna %d: %w", riga, colonna, err)
+}
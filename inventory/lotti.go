@@ -0,0 +1,234 @@
+package inventory
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/branila/restaurant-protocol/errors"
+	"github.com/branila/restaurant-protocol/models"
+)
+
+// MovimentoScarto toglie dalla dispensa un lotto scaduto
+const MovimentoScarto = "scarto"
+
+// Lotto è una partita di un ingrediente con la sua scadenza, per la tracciabilità HACCP.
+// I lotti di un ingrediente sono in ordine di arrivo e vengono consumati dal più vecchio
+type Lotto struct {
+	Codice   string    `json:"codice"`
+	Quantita float64   `json:"quantita"`
+	Scadenza time.Time `json:"scadenza"`
+}
+
+// ScadutoAl indica se il lotto è scaduto nel giorno indicato. Il lotto si può usare
+// fino a tutto il giorno di scadenza
+func (l Lotto) ScadutoAl(giorno time.Time) bool {
+	return soloData(giorno).After(soloData(l.Scadenza))
+}
+
+// SenzaLotto restituisce la scorta non attribuita ad alcun lotto, ad esempio quella
+// caricata prima di tracciare i lotti. Viene consumata per prima e non scade
+func (i Ingrediente) SenzaLotto() float64 {
+	quantita := i.Quantita
+	for _, lotto := range i.Lotti {
+		quantita -= lotto.Quantita
+	}
+	return max(quantita, 0)
+}
+
+// DisponibileAl restituisce la scorta utilizzabile nel giorno indicato, esclusi i lotti scaduti
+func (i Ingrediente) DisponibileAl(giorno time.Time) float64 {
+	quantita := i.Quantita
+	for _, lotto := range i.Lotti {
+		if lotto.ScadutoAl(giorno) {
+			quantita -= lotto.Quantita
+		}
+	}
+	return quantita
+}
+
+// RifornisciLotto aggiunge alla dispensa un nuovo lotto di un ingrediente
+func (inv *Inventory) RifornisciLotto(nomeIngrediente string, lotto Lotto, operatore string, motivo string) error {
+	if lotto.Codice == "" {
+		return fmt.Errorf("indicare il codice del lotto")
+	}
+	if lotto.Quantita <= 0 {
+		return fmt.Errorf("la quantità del lotto deve essere positiva (%g)", lotto.Quantita)
+	}
+	if lotto.Scadenza.IsZero() {
+		return fmt.Errorf("indicare la scadenza del lotto")
+	}
+
+	return inv.operazione(Movimento{
+		Tipo:        MovimentoRifornimento,
+		Ingrediente: nomeIngrediente,
+		Lotto:       lotto.Codice,
+		Scadenza:    lotto.Scadenza.Format(FormatoData),
+		Quantita:    lotto.Quantita,
+		Operatore:   operatore,
+		Motivo:      motivo,
+	})
+}
+
+// ScartaScaduti toglie dalla dispensa tutti i lotti scaduti nel giorno indicato
+// e restituisce i movimenti di scarto registrati
+func (inv *Inventory) ScartaScaduti(giorno time.Time, operatore string) ([]Movimento, error) {
+	if operatore == "" {
+		return nil, fmt.Errorf("indicare l'operatore che esegue l'operazione")
+	}
+
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	var scarti []Movimento
+	for _, nome := range ordinaChiavi(inv.ingredienti) {
+		for _, lotto := range inv.ingredienti[nome].Lotti {
+			if !lotto.ScadutoAl(giorno) {
+				continue
+			}
+			scarti = append(scarti, Movimento{
+				Tipo:        MovimentoScarto,
+				Ingrediente: nome,
+				Lotto:       lotto.Codice,
+				Scadenza:    lotto.Scadenza.Format(FormatoData),
+				Quantita:    lotto.Quantita,
+				Operatore:   operatore,
+				Motivo:      fmt.Sprintf("scaduto il %s", lotto.Scadenza.Format(FormatoData)),
+			})
+		}
+	}
+	if len(scarti) == 0 {
+		return nil, nil
+	}
+
+	if err := inv.registra(scarti...); err != nil {
+		return nil, err
+	}
+	for _, m := range scarti {
+		inv.applica(m)
+	}
+
+	return scarti, nil
+}
+
+// prelievo calcola da quali lotti prendere la quantità indicata di un ingrediente: prima la
+// scorta senza lotto, poi i lotti non scaduti dal più vecchio. usati tiene conto di quanto è già
+// stato prelevato dallo stesso ordine. Va chiamata con il lock già acquisito, dopo verificaIngredienti
+func (inv *Inventory) prelievo(nome string, quantita float64, giorno time.Time, usati map[string]float64) []Movimento {
+	ingrediente := inv.ingredienti[nome]
+	var movimenti []Movimento
+
+	preleva := func(codice string, scadenza string, disponibile float64) {
+		chiave := nome + "\x00" + codice
+		presa := min(quantita, disponibile-usati[chiave])
+		if presa <= 1e-9 {
+			return
+		}
+		usati[chiave] += presa
+		quantita -= presa
+		movimenti = append(movimenti, Movimento{
+			Tipo:        MovimentoDecremento,
+			Ingrediente: nome,
+			Lotto:       codice,
+			Scadenza:    scadenza,
+			Quantita:    presa,
+		})
+	}
+
+	preleva("", "", ingrediente.SenzaLotto())
+	for _, lotto := range ingrediente.Lotti {
+		if quantita <= 0 {
+			break
+		}
+		if !lotto.ScadutoAl(giorno) {
+			preleva(lotto.Codice, lotto.Scadenza.Format(FormatoData), lotto.Quantita)
+		}
+	}
+
+	return movimenti
+}
+
+// applicaLotti aggiorna i lotti dell'ingrediente secondo il movimento, prima che
+// cambi la quantità totale
+func applicaLotti(ingrediente Ingrediente, m Movimento) []Lotto {
+	lotti := slices.Clone(ingrediente.Lotti)
+
+	switch m.Tipo {
+	case MovimentoDecremento, MovimentoScarto:
+		if m.Lotto != "" {
+			lotti = togliDaLotto(lotti, m.Lotto, m.Quantita)
+		}
+	case MovimentoRifornimento, MovimentoAnnullamento:
+		if m.Lotto == "" {
+			break
+		}
+		if i := slices.IndexFunc(lotti, func(l Lotto) bool { return l.Codice == m.Lotto }); i >= 0 {
+			lotti[i].Quantita += m.Quantita
+			break
+		}
+		scadenza, _ := time.Parse(FormatoData, m.Scadenza)
+		lotto := Lotto{Codice: m.Lotto, Quantita: m.Quantita, Scadenza: scadenza}
+		if m.Tipo == MovimentoAnnullamento {
+			// Un lotto restituito era il più vecchio, quindi torna in testa
+			lotti = slices.Insert(lotti, 0, lotto)
+		} else {
+			lotti = append(lotti, lotto)
+		}
+	case MovimentoRettifica:
+		// Una scorta contata inferiore si considera consumata dai lotti più vecchi
+		mancante := ingrediente.Quantita - m.Quantita - ingrediente.SenzaLotto()
+		for len(lotti) > 0 && mancante > 0 {
+			presa := min(mancante, lotti[0].Quantita)
+			lotti = togliDaLotto(lotti, lotti[0].Codice, presa)
+			mancante -= presa
+		}
+	}
+
+	return lotti
+}
+
+// togliDaLotto scala la quantità dal lotto indicato, eliminandolo quando si esaurisce
+func togliDaLotto(lotti []Lotto, codice string, quantita float64) []Lotto {
+	i := slices.IndexFunc(lotti, func(l Lotto) bool { return l.Codice == codice })
+	if i < 0 {
+		return lotti
+	}
+	lotti[i].Quantita -= quantita
+	if lotti[i].Quantita <= 1e-9 {
+		lotti = slices.Delete(lotti, i, i+1)
+	}
+	return lotti
+}
+
+// tracciaLotti raccoglie i lotti usati dai movimenti di un piatto
+func tracciaLotti(movimenti []Movimento) []models.TracciaLotto {
+	var traccia []models.TracciaLotto
+	for _, m := range movimenti {
+		if m.Lotto == "" {
+			continue
+		}
+		traccia = append(traccia, models.TracciaLotto{
+			Ingrediente: m.Ingrediente,
+			Lotto:       m.Lotto,
+			Scadenza:    m.Scadenza,
+			Quantita:    m.Quantita,
+		})
+	}
+	return traccia
+}
+
+// erroreScaduti spiega la mancanza di un ingrediente dovuta a lotti scaduti
+func erroreScaduti(nomePiatto string, ingrediente Ingrediente, richiesta float64, giorno time.Time) error {
+	return errors.NewIngredienteEsauritoError(
+		nomePiatto,
+		ingrediente.Nome,
+		fmt.Sprintf("Servono %g %s, ne restano %g non scaduti al %s: scartare i lotti scaduti",
+			richiesta, ingrediente.Unita, ingrediente.DisponibileAl(giorno), giorno.Format(FormatoData)),
+	)
+}
+
+// soloData elimina l'orario, per confrontare i giorni
+func soloData(t time.Time) time.Time {
+	anno, mese, giorno := t.Date()
+	return time.Date(anno, mese, giorno, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,184 @@
+package inventory
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/branila/restaurant-protocol/money"
+)
+
+// Criteri di ordinamento del menu
+const (
+	OrdinaPerPortata   = "portata"
+	OrdinaPerNome      = "nome"
+	OrdinaPerPrezzo    = "prezzo"
+	OrdinaPerRimanenti = "rimanenti"
+)
+
+// VoceMenu è la descrizione di un piatto pensata per la consultazione e l'esportazione del menu
+type VoceMenu struct {
+	Nome         string
+	Portata      string
+	Stazione     string
+	AltrePortate []string `json:",omitempty" yaml:",omitempty"`
+	Prezzo       money.Importo
+	Rimanenti    float64
+	Disponibile  bool
+	Varianti     []VoceVariante      `json:",omitempty" yaml:",omitempty"`
+	Allergeni    []string            `json:",omitempty" yaml:",omitempty"`
+	Tag          []string            `json:",omitempty" yaml:",omitempty"`
+	SuRichiesta  map[string][]string `json:",omitempty" yaml:",omitempty"` // tag -> voci da rimuovere per ottenerlo
+	Orari        string              `json:",omitempty" yaml:",omitempty"` // quando il piatto viene servito, se limitato
+}
+
+// VoceVariante descrive una variante del piatto con il suo prezzo
+type VoceVariante struct {
+	Nome     string
+	Prezzo   money.Importo
+	Porzioni float64
+}
+
+// FiltroMenu seleziona e ordina le voci del menu; i campi vuoti non filtrano
+type FiltroMenu struct {
+	Portata          string
+	Stazione         string
+	SoloDisponibili  bool
+	Giorno           time.Time // se indicato, la disponibilità tiene conto del calendario del piatto
+	Ora              string
+	SenzaAllergeni   []string // esclude i piatti che contengono uno di questi allergeni
+	Tag              []string // richiede tutti questi tag (es. "vegano")
+	AncheSuRichiesta bool     // accetta anche i piatti che ottengono i tag rimuovendo qualche voce
+	Ordinamento      string   // predefinito: OrdinaPerPortata
+}
+
+// Menu restituisce le voci del menu che corrispondono al filtro, ordinate come richiesto
+func (inv *Inventory) Menu(filtro FiltroMenu) ([]VoceMenu, error) {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	var voci []VoceMenu
+	for _, piatto := range inv.piatti {
+		if filtro.Portata != "" && piatto.Portata != filtro.Portata && !slices.Contains(piatto.AltrePortate, filtro.Portata) {
+			continue
+		}
+		if filtro.Stazione != "" && stazionePiatto(piatto) != filtro.Stazione {
+			continue
+		}
+		if slices.ContainsFunc(filtro.SenzaAllergeni, func(a string) bool { return slices.Contains(piatto.Allergeni, a) }) {
+			continue
+		}
+
+		voce := inv.voceMenu(piatto, filtro.Giorno, filtro.Ora)
+		if !voce.haTag(filtro.Tag, filtro.AncheSuRichiesta) {
+			continue
+		}
+		if filtro.SoloDisponibili && !voce.Disponibile {
+			continue
+		}
+		voci = append(voci, voce)
+	}
+
+	if err := ordinaMenu(voci, filtro.Ordinamento); err != nil {
+		return nil, err
+	}
+
+	return voci, nil
+}
+
+// voceMenu costruisce la voce del menu di un piatto. Va chiamata con il lock già acquisito
+func (inv *Inventory) voceMenu(piatto Piatto, giorno time.Time, ora string) VoceMenu {
+	scadenze := giorno
+	if scadenze.IsZero() {
+		scadenze = time.Now()
+	}
+	disponibile := inv.verificaDisponibilita(piatto.Nome, scadenze) == nil
+
+	voce := VoceMenu{
+		Nome:         piatto.Nome,
+		Portata:      piatto.Portata,
+		Stazione:     stazionePiatto(piatto),
+		AltrePortate: piatto.AltrePortate,
+		Prezzo:       piatto.Prezzo,
+		Rimanenti:    piatto.Disponibilita,
+		Allergeni:    piatto.Allergeni,
+		Tag:          inv.tagPiatto(piatto, nil),
+		SuRichiesta:  inv.tagSuRichiesta(piatto),
+	}
+
+	for _, nome := range ordinaChiavi(piatto.Varianti) {
+		variante := piatto.Varianti[nome]
+		voce.Varianti = append(voce.Varianti, VoceVariante{
+			Nome:     nome,
+			Prezzo:   piatto.Prezzo.Percentuale(variante.PercentualePrezzo),
+			Porzioni: variante.Porzioni,
+		})
+	}
+
+	if piatto.Calendario != nil {
+		voce.Orari = piatto.Calendario.Descrizione()
+		if !giorno.IsZero() && piatto.Calendario.verifica(piatto.Nome, giorno, ora) != nil {
+			disponibile = false
+		}
+	}
+	voce.Disponibile = disponibile
+
+	return voce
+}
+
+// haTag indica se la voce ha tutti i tag richiesti, anche su richiesta se ammesso
+func (voce VoceMenu) haTag(richiesti []string, suRichiesta bool) bool {
+	for _, tag := range richiesti {
+		if slices.Contains(voce.Tag, tag) {
+			continue
+		}
+		if _, ok := voce.SuRichiesta[tag]; !suRichiesta || !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// ordinePortate è l'ordine in cui le portate compaiono nel menu
+var ordinePortate = map[string]int{
+	PortataPrimo:    0,
+	PortataSecondo:  1,
+	PortataContorno: 2,
+	"":              3,
+}
+
+func ordinaMenu(voci []VoceMenu, ordinamento string) error {
+	var meno func(a, b VoceMenu) bool
+
+	switch ordinamento {
+	case "", OrdinaPerPortata:
+		meno = func(a, b VoceMenu) bool {
+			if ordinePortate[a.Portata] != ordinePortate[b.Portata] {
+				return ordinePortate[a.Portata] < ordinePortate[b.Portata]
+			}
+			return a.Nome < b.Nome
+		}
+	case OrdinaPerNome:
+		meno = func(a, b VoceMenu) bool { return a.Nome < b.Nome }
+	case OrdinaPerPrezzo:
+		meno = func(a, b VoceMenu) bool {
+			if a.Prezzo != b.Prezzo {
+				return a.Prezzo < b.Prezzo
+			}
+			return a.Nome < b.Nome
+		}
+	case OrdinaPerRimanenti:
+		meno = func(a, b VoceMenu) bool {
+			if a.Rimanenti != b.Rimanenti {
+				return a.Rimanenti < b.Rimanenti
+			}
+			return a.Nome < b.Nome
+		}
+	default:
+		return fmt.Errorf("ordinamento %q non valido, usare portata, nome, prezzo o rimanenti", ordinamento)
+	}
+
+	sort.Slice(voci, func(i, j int) bool { return meno(voci[i], voci[j]) })
+	return nil
+}
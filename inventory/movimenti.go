@@ -0,0 +1,119 @@
+package inventory
+
+import (
+	"fmt"
+	"time"
+)
+
+// Tipi di movimento registrati nel giornale
+const (
+	MovimentoDecremento    = "decremento"
+	MovimentoRifornimento  = "rifornimento"
+	MovimentoAnnullamento  = "annullamento" // restituisce quanto scalato da una prenotazione annullata
+	MovimentoRettifica     = "rettifica"    // imposta un valore assoluto dopo un conteggio
+	MovimentoSospensione   = "sospensione"  // "86": piatto non servibile, le porzioni restano
+	MovimentoRiattivazione = "riattivazione"
+	MovimentoInserimento   = "inserimento" // piatto o ingrediente aggiunto all'inventario, con la quantità iniziale
+	MovimentoApertura      = "apertura"    // quantità all'inizio del servizio, registrata dal reset dello stato
+)
+
+// Movimento descrive una variazione delle porzioni di un piatto o della scorta di un ingrediente
+type Movimento struct {
+	Seq         int64     `json:"seq"`
+	Tipo        string    `json:"tipo"`
+	Piatto      string    `json:"piatto,omitempty"`
+	Ingrediente string    `json:"ingrediente,omitempty"`
+	Lotto       string    `json:"lotto,omitempty"`
+	Scadenza    string    `json:"scadenza,omitempty"` // scadenza del lotto, DD/MM/YYYY
+	Quantita    float64   `json:"quantita"`           // per le rettifiche è il nuovo valore assoluto
+	Operatore   string    `json:"operatore,omitempty"`
+	Motivo      string    `json:"motivo,omitempty"`
+	Ordine      string    `json:"ordine,omitempty"`  // ordine che ha originato il movimento, es. "tavolo 1 del 13/11/2025"
+	Comanda     int       `json:"comanda,omitempty"` // comanda dell'ordine, significativa solo se Ordine è indicato
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// Registro riceve i movimenti dell'inventario, ad esempio per renderli persistenti.
+// I movimenti passati insieme appartengono alla stessa operazione; il registro può
+// assegnare loro il numero progressivo, che viene conservato nello storico in memoria
+type Registro interface {
+	Registra(movimenti ...Movimento) error
+}
+
+// SetRegistro collega un registro all'inventario; nil lo scollega
+func (inv *Inventory) SetRegistro(r Registro) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	inv.registro = r
+}
+
+// registra inoltra i movimenti al registro, se presente. Va chiamata con il lock già acquisito
+func (inv *Inventory) registra(movimenti ...Movimento) error {
+	adesso := time.Now()
+	for i := range movimenti {
+		if movimenti[i].Timestamp.IsZero() {
+			movimenti[i].Timestamp = adesso
+		}
+	}
+	if inv.registro == nil {
+		return nil
+	}
+	if err := inv.registro.Registra(movimenti...); err != nil {
+		return fmt.Errorf("errore nella registrazione del movimento: %w", err)
+	}
+	return nil
+}
+
+// applica un movimento già registrato senza inoltrarlo di nuovo al registro
+// e lo aggiunge allo storico
+func (inv *Inventory) applica(m Movimento) {
+	inv.storico = append(inv.storico, m)
+
+	if m.Ingrediente != "" {
+		inv.applicaIngrediente(m)
+		return
+	}
+
+	piatto, exists := inv.piatti[m.Piatto]
+	if !exists {
+		return
+	}
+
+	switch m.Tipo {
+	case MovimentoDecremento:
+		piatto.Disponibilita = arrotondaPorzioni(piatto.Disponibilita - m.Quantita)
+	case MovimentoRifornimento, MovimentoAnnullamento:
+		piatto.Disponibilita = arrotondaPorzioni(piatto.Disponibilita + m.Quantita)
+	case MovimentoRettifica, MovimentoInserimento, MovimentoApertura:
+		piatto.Disponibilita = m.Quantita
+	case MovimentoSospensione:
+		piatto.Sospeso = true
+		piatto.MotivoSospensione = m.Motivo
+	case MovimentoRiattivazione:
+		piatto.Sospeso = false
+		piatto.MotivoSospensione = ""
+	}
+	inv.piatti[m.Piatto] = piatto
+}
+
+func (inv *Inventory) applicaIngrediente(m Movimento) {
+	// I giornali scritti prima delle scorte per stazione indicano solo il nome dell'ingrediente
+	chiave, err := inv.scorta(m.Ingrediente)
+	if err != nil {
+		return
+	}
+	ingrediente := inv.ingredienti[chiave]
+
+	ingrediente.Lotti = applicaLotti(ingrediente, m)
+
+	switch m.Tipo {
+	case MovimentoDecremento, MovimentoScarto:
+		ingrediente.Quantita -= m.Quantita
+	case MovimentoRifornimento, MovimentoAnnullamento:
+		ingrediente.Quantita += m.Quantita
+	case MovimentoRettifica, MovimentoInserimento, MovimentoApertura:
+		ingrediente.Quantita = m.Quantita
+	}
+	inv.ingredienti[chiave] = ingrediente
+}
@@ -0,0 +1,184 @@
+package inventory
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FiltroStorico seleziona i movimenti dello storico; i campi vuoti non filtrano
+type FiltroStorico struct {
+	Piatto      string
+	Ingrediente string
+	Tipo        string
+	Operatore   string
+	Ordine      string // riferimento dell'ordine, es. "tavolo 1 del 13/11/2025"
+	Dal         time.Time
+	Al          time.Time
+}
+
+// Rifornisci aggiunge porzioni a un piatto
+func (inv *Inventory) Rifornisci(nomePiatto string, porzioni float64, operatore string, motivo string) error {
+	if porzioni <= 0 {
+		return fmt.Errorf("le porzioni del rifornimento devono essere positive (%g)", porzioni)
+	}
+
+	return inv.operazione(Movimento{
+		Tipo:      MovimentoRifornimento,
+		Piatto:    nomePiatto,
+		Quantita:  porzioni,
+		Operatore: operatore,
+		Motivo:    motivo,
+	})
+}
+
+// RifornisciIngrediente aggiunge scorta a un ingrediente, nella sua unità di misura
+func (inv *Inventory) RifornisciIngrediente(nomeIngrediente string, quantita float64, operatore string, motivo string) error {
+	if quantita <= 0 {
+		return fmt.Errorf("la quantità del rifornimento deve essere positiva (%g)", quantita)
+	}
+
+	return inv.operazione(Movimento{
+		Tipo:        MovimentoRifornimento,
+		Ingrediente: nomeIngrediente,
+		Quantita:    quantita,
+		Operatore:   operatore,
+		Motivo:      motivo,
+	})
+}
+
+// Rettifica imposta le porzioni di un piatto al valore contato
+func (inv *Inventory) Rettifica(nomePiatto string, porzioni float64, operatore string, motivo string) error {
+	if porzioni < 0 {
+		return fmt.Errorf("le porzioni non possono essere negative (%g)", porzioni)
+	}
+	if motivo == "" {
+		return fmt.Errorf("indicare il motivo della rettifica")
+	}
+
+	return inv.operazione(Movimento{
+		Tipo:      MovimentoRettifica,
+		Piatto:    nomePiatto,
+		Quantita:  porzioni,
+		Operatore: operatore,
+		Motivo:    motivo,
+	})
+}
+
+// RettificaIngrediente imposta la scorta di un ingrediente al valore contato
+func (inv *Inventory) RettificaIngrediente(nomeIngrediente string, quantita float64, operatore string, motivo string) error {
+	if quantita < 0 {
+		return fmt.Errorf("la quantità non può essere negativa (%g)", quantita)
+	}
+	if motivo == "" {
+		return fmt.Errorf("indicare il motivo della rettifica")
+	}
+
+	return inv.operazione(Movimento{
+		Tipo:        MovimentoRettifica,
+		Ingrediente: nomeIngrediente,
+		Quantita:    quantita,
+		Operatore:   operatore,
+		Motivo:      motivo,
+	})
+}
+
+// Sospendi toglie il piatto dal servizio ("86") senza azzerare le porzioni rimaste
+func (inv *Inventory) Sospendi(nomePiatto string, operatore string, motivo string) error {
+	if motivo == "" {
+		return fmt.Errorf("indicare il motivo della sospensione")
+	}
+
+	return inv.operazione(Movimento{
+		Tipo:      MovimentoSospensione,
+		Piatto:    nomePiatto,
+		Operatore: operatore,
+		Motivo:    motivo,
+	})
+}
+
+// Riattiva rimette in servizio un piatto sospeso
+func (inv *Inventory) Riattiva(nomePiatto string, operatore string, motivo string) error {
+	return inv.operazione(Movimento{
+		Tipo:      MovimentoRiattivazione,
+		Piatto:    nomePiatto,
+		Operatore: operatore,
+		Motivo:    motivo,
+	})
+}
+
+// Storico restituisce i movimenti applicati che corrispondono al filtro, dal più vecchio
+func (inv *Inventory) Storico(filtro FiltroStorico) []Movimento {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	var risultato []Movimento
+	for _, m := range inv.storico {
+		if filtro.corrisponde(m) {
+			risultato = append(risultato, m)
+		}
+	}
+
+	return risultato
+}
+
+func (f FiltroStorico) corrisponde(m Movimento) bool {
+	switch {
+	case f.Piatto != "" && m.Piatto != f.Piatto:
+		return false
+	case f.Ingrediente != "" && m.Ingrediente != f.Ingrediente && !strings.HasSuffix(m.Ingrediente, "/"+f.Ingrediente):
+		return false
+	case f.Tipo != "" && m.Tipo != f.Tipo:
+		return false
+	case f.Operatore != "" && m.Operatore != f.Operatore:
+		return false
+	case f.Ordine != "" && m.Ordine != f.Ordine:
+		return false
+	case !f.Dal.IsZero() && m.Timestamp.Before(f.Dal):
+		return false
+	case !f.Al.IsZero() && m.Timestamp.After(f.Al):
+		return false
+	}
+	return true
+}
+
+// operazione registra e applica un movimento manuale, quindi notifica gli eventi
+func (inv *Inventory) operazione(m Movimento) error {
+	eventi, err := inv.eseguiOperazione(m)
+	inv.notifica(eventi)
+	return err
+}
+
+// eseguiOperazione registra e applica insieme uno o più movimenti manuali
+func (inv *Inventory) eseguiOperazione(movimenti ...Movimento) ([]Evento, error) {
+	for _, m := range movimenti {
+		if m.Operatore == "" {
+			return nil, fmt.Errorf("indicare l'operatore che esegue l'operazione")
+		}
+	}
+
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	for i, m := range movimenti {
+		if m.Ingrediente != "" {
+			chiave, err := inv.scorta(m.Ingrediente)
+			if err != nil {
+				return nil, err
+			}
+			movimenti[i].Ingrediente = chiave
+		} else if _, exists := inv.piatti[m.Piatto]; !exists {
+			return nil, fmt.Errorf("il piatto '%s' non esiste nel menu", m.Piatto)
+		}
+	}
+
+	if err := inv.registra(movimenti...); err != nil {
+		return nil, err
+	}
+	prima := inv.statiPrima(movimenti)
+	for _, m := range movimenti {
+		inv.applica(m)
+	}
+
+	return inv.eventiMovimenti(prima), nil
+}
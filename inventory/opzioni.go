@@ -0,0 +1,103 @@
+package inventory
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/branila/restaurant-protocol/errors"
+)
+
+// Opzione descrive una scelta da indicare al momento dell'ordine (cottura, formato, temperatura...)
+type Opzione struct {
+	Nome         string
+	Obbligatoria bool
+	Valori       []string // valori ammessi, vuoto = qualsiasi valore
+}
+
+// AddOpzione dichiara un'opzione per un piatto già presente nell'inventario
+func (inv *Inventory) AddOpzione(nomePiatto string, opzione Opzione) error {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	piatto, exists := inv.piatti[nomePiatto]
+	if !exists {
+		return errors.NewPiattoInesistenteError(nomePiatto)
+	}
+
+	if piatto.Opzioni == nil {
+		piatto.Opzioni = make(map[string]Opzione)
+	}
+	piatto.Opzioni[opzione.Nome] = opzione
+	inv.piatti[nomePiatto] = piatto
+
+	return nil
+}
+
+// VerificaOpzione controlla che l'opzione esista per il piatto e che il valore sia ammesso
+func (inv *Inventory) VerificaOpzione(nomePiatto string, nomeOpzione string, valore string) error {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	piatto, exists := inv.piatti[nomePiatto]
+	if !exists {
+		return errors.NewPiattoInesistenteError(nomePiatto)
+	}
+
+	opzione, exists := piatto.Opzioni[nomeOpzione]
+	if !exists {
+		return errors.NewOpzioneNonValidaError(
+			nomePiatto,
+			nomeOpzione,
+			"Questa opzione non è prevista per il piatto",
+		)
+	}
+
+	if len(opzione.Valori) == 0 {
+		return nil
+	}
+
+	for _, ammesso := range opzione.Valori {
+		if ammesso == valore {
+			return nil
+		}
+	}
+
+	return errors.NewOpzioneNonValidaError(
+		nomePiatto,
+		nomeOpzione,
+		fmt.Sprintf("Il valore '%s' non è ammesso, scegliere tra: %s", valore, strings.Join(opzione.Valori, ", ")),
+	)
+}
+
+// VerificaOpzioniObbligatorie controlla che tutte le opzioni obbligatorie siano state indicate
+func (inv *Inventory) VerificaOpzioniObbligatorie(nomePiatto string, presenti []string) error {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	piatto, exists := inv.piatti[nomePiatto]
+	if !exists {
+		return errors.NewPiattoInesistenteError(nomePiatto)
+	}
+
+	indicate := make(map[string]bool, len(presenti))
+	for _, nome := range presenti {
+		indicate[nome] = true
+	}
+
+	// Ordina i nomi per restituire sempre lo stesso errore a parità di input
+	nomi := make([]string, 0, len(piatto.Opzioni))
+	for nome := range piatto.Opzioni {
+		nomi = append(nomi, nome)
+	}
+	sort.Strings(nomi)
+
+	for _, nome := range nomi {
+		opzione := piatto.Opzioni[nome]
+		if opzione.Obbligatoria && !indicate[nome] {
+			return errors.NewOpzioneMancanteError(nomePiatto, nome, opzione.Valori)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,199 @@
+package inventory
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/branila/restaurant-protocol/errors"
+	"github.com/branila/restaurant-protocol/models"
+)
+
+// Richiesta è un piatto da scalare dall'inventario insieme alle sue modifiche
+type Richiesta struct {
+	Piatto    string
+	Variante  string // vuota per la porzione normale
+	Modifiche []models.Modifica
+	Ordine    string // riferimento dell'ordine da riportare nello storico, facoltativo
+	Comanda   int
+}
+
+// Prenotazione trattiene porzioni e ingredienti di un intero ordine
+// finché non viene confermata o annullata
+type Prenotazione struct {
+	inv       *Inventory
+	movimenti []Movimento
+	tracce    [][]models.TracciaLotto // lotti usati da ogni richiesta
+	chiusa    bool
+	mu        sync.Mutex
+}
+
+// Riserva verifica e scala in un'unica sezione critica tutti i piatti richiesti.
+// Se anche un solo piatto non è disponibile, l'inventario resta invariato.
+func (inv *Inventory) Riserva(richieste []Richiesta) (*Prenotazione, error) {
+	return inv.RiservaAl(richieste, time.Now())
+}
+
+// RiservaAl è come Riserva, ma esclude i lotti scaduti nel giorno indicato, di solito quello dell'ordine
+func (inv *Inventory) RiservaAl(richieste []Richiesta, giorno time.Time) (*Prenotazione, error) {
+	prenotazione, eventi, err := inv.riserva(richieste, giorno)
+	inv.notifica(eventi)
+	return prenotazione, err
+}
+
+func (inv *Inventory) riserva(richieste []Richiesta, giorno time.Time) (*Prenotazione, []Evento, error) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	perRichiesta, err := inv.pianifica(richieste, giorno)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var movimenti []Movimento
+	tracce := make([][]models.TracciaLotto, len(perRichiesta))
+	for i, m := range perRichiesta {
+		movimenti = append(movimenti, m...)
+		tracce[i] = tracciaLotti(m)
+	}
+
+	if err := inv.registra(movimenti...); err != nil {
+		return nil, nil, err
+	}
+	prima := inv.statiPrima(movimenti)
+	for _, m := range movimenti {
+		inv.applica(m)
+	}
+
+	return &Prenotazione{inv: inv, movimenti: movimenti, tracce: tracce}, inv.eventiMovimenti(prima), nil
+}
+
+// Tracciabilita restituisce, per ogni richiesta nell'ordine in cui è stata passata,
+// i lotti degli ingredienti che le sono stati assegnati
+func (p *Prenotazione) Tracciabilita() [][]models.TracciaLotto {
+	return p.tracce
+}
+
+// Consuma scala definitivamente tutti i piatti richiesti, oppure nessuno
+func (inv *Inventory) Consuma(richieste []Richiesta) error {
+	prenotazione, err := inv.Riserva(richieste)
+	if err != nil {
+		return err
+	}
+	return prenotazione.Conferma()
+}
+
+// Conferma rende definitiva la prenotazione
+func (p *Prenotazione) Conferma() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.chiusa {
+		return fmt.Errorf("prenotazione già chiusa")
+	}
+	p.chiusa = true
+
+	return nil
+}
+
+// Annulla restituisce all'inventario porzioni e ingredienti trattenuti dalla prenotazione
+func (p *Prenotazione) Annulla() error {
+	eventi, err := p.annulla()
+	p.inv.notifica(eventi)
+	return err
+}
+
+func (p *Prenotazione) annulla() ([]Evento, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.chiusa {
+		return nil, fmt.Errorf("prenotazione già chiusa")
+	}
+
+	p.inv.mu.Lock()
+	defer p.inv.mu.Unlock()
+
+	restituzioni := make([]Movimento, len(p.movimenti))
+	for i, m := range p.movimenti {
+		restituzioni[i] = Movimento{
+			Tipo:        MovimentoAnnullamento,
+			Piatto:      m.Piatto,
+			Ingrediente: m.Ingrediente,
+			Lotto:       m.Lotto,
+			Scadenza:    m.Scadenza,
+			Quantita:    m.Quantita,
+			Ordine:      m.Ordine,
+			Comanda:     m.Comanda,
+		}
+	}
+
+	if err := p.inv.registra(restituzioni...); err != nil {
+		return nil, err
+	}
+	prima := p.inv.statiPrima(restituzioni)
+	for _, m := range restituzioni {
+		p.inv.applica(m)
+	}
+	p.chiusa = true
+
+	return p.inv.eventiMovimenti(prima), nil
+}
+
+// pianifica calcola i movimenti necessari per ogni richiesta, controllando che
+// porzioni e ingredienti non scaduti bastino anche sommando i piatti dello stesso ordine.
+// Va chiamata con il lock già acquisito
+func (inv *Inventory) pianifica(richieste []Richiesta, giorno time.Time) ([][]Movimento, error) {
+	porzioni := make(map[string]float64)
+	dosiTotali := make(map[string]float64)
+	usati := make(map[string]float64)
+	perRichiesta := make([][]Movimento, 0, len(richieste))
+
+	for _, r := range richieste {
+		piatto, exists := inv.piatti[r.Piatto]
+		if !exists {
+			return nil, errors.NewPiattoInesistenteError(r.Piatto)
+		}
+
+		if piatto.Sospeso {
+			return nil, errors.NewPiattoSospesoError(r.Piatto, piatto.MotivoSospensione)
+		}
+
+		variante, err := piatto.variante(r.Variante)
+		if err != nil {
+			return nil, err
+		}
+
+		rimanenti := arrotondaPorzioni(piatto.Disponibilita - porzioni[r.Piatto])
+		if rimanenti < variante.Porzioni {
+			return nil, errors.NewPiattoEsauritoError(r.Piatto, rimanenti)
+		}
+		porzioni[r.Piatto] += variante.Porzioni
+
+		// Le varianti consumano ingredienti in proporzione alle porzioni
+		dosi := inv.fabbisogno(piatto, r.Modifiche)
+		for ingrediente := range dosi {
+			dosi[ingrediente] *= variante.Porzioni
+		}
+		cumulative := make(map[string]float64, len(dosi))
+		for ingrediente, quantita := range dosi {
+			cumulative[ingrediente] = dosiTotali[ingrediente] + quantita
+		}
+		if err := inv.verificaIngredienti(r.Piatto, cumulative, giorno); err != nil {
+			return nil, err
+		}
+
+		movimenti := []Movimento{{Tipo: MovimentoDecremento, Piatto: r.Piatto, Quantita: variante.Porzioni}}
+		for _, ingrediente := range ordinaChiavi(dosi) {
+			dosiTotali[ingrediente] += dosi[ingrediente]
+			movimenti = append(movimenti, inv.prelievo(ingrediente, dosi[ingrediente], giorno, usati)...)
+		}
+		for i := range movimenti {
+			movimenti[i].Ordine = r.Ordine
+			movimenti[i].Comanda = r.Comanda
+		}
+		perRichiesta = append(perRichiesta, movimenti)
+	}
+
+	return perRichiesta, nil
+}
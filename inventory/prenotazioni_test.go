@@ -0,0 +1,80 @@
+package inventory
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// Molti clienti si contendono le ultime porzioni: con go test -race verifica che Riserva
+// non venda mai più di quanto disponibile, sia quando finiscono le porzioni sia quando
+// finisce un ingrediente
+func TestRiservaConcorrenteNonVendePiuDelDisponibile(t *testing.T) {
+	const (
+		clienti   = 64
+		rimaste   = 7
+		dose      = 10.0 // grammi di farina per porzione
+		tentativi = 3    // ogni cliente riprova dopo un rifiuto
+	)
+
+	casi := []struct {
+		nome      string
+		porzioni  float64
+		farina    float64
+		esaurisce string
+	}{
+		{"porzioni", rimaste, 1000, "porzioni"},
+		{"ingrediente", 1000, rimaste * dose, "farina"},
+	}
+
+	for _, c := range casi {
+		t.Run(c.nome, func(t *testing.T) {
+			inv := New()
+			if err := inv.SetIngrediente(Ingrediente{Nome: "farina", Quantita: c.farina, Unita: UnitaGrammi}); err != nil {
+				t.Fatal(err)
+			}
+			if err := inv.SetPiatto(Piatto{Nome: "pizza", Disponibilita: c.porzioni, Ricetta: map[string]float64{"farina": dose}}); err != nil {
+				t.Fatal(err)
+			}
+
+			var riuscite atomic.Int64
+			var wg sync.WaitGroup
+			partenza := make(chan struct{})
+			for i := 0; i < clienti; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					<-partenza
+					for j := 0; j < tentativi; j++ {
+						prenotazione, err := inv.Riserva([]Richiesta{{Piatto: "pizza"}})
+						if err != nil {
+							continue
+						}
+						if err := prenotazione.Conferma(); err != nil {
+							t.Error(err)
+							return
+						}
+						riuscite.Add(1)
+						return
+					}
+				}()
+			}
+			close(partenza)
+			wg.Wait()
+
+			if n := riuscite.Load(); n != rimaste {
+				t.Fatalf("riservate %d porzioni, attese %d", n, rimaste)
+			}
+
+			piatto, _ := inv.GetPiatto("pizza")
+			farina, _ := inv.GetIngrediente("farina")
+			rimanenti := map[string]float64{"porzioni": piatto.Disponibilita, "farina": farina.Quantita}
+			if rimanenti[c.esaurisce] != 0 {
+				t.Fatalf("%s rimaste: %g, attese 0", c.esaurisce, rimanenti[c.esaurisce])
+			}
+			if piatto.Disponibilita < 0 || farina.Quantita < 0 {
+				t.Fatalf("scorte negative: %g porzioni, %g g di farina", piatto.Disponibilita, farina.Quantita)
+			}
+		})
+	}
+}
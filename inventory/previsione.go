@@ -0,0 +1,239 @@
+package inventory
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/branila/restaurant-protocol/models"
+)
+
+// Valori predefiniti della previsione
+const (
+	FinestraPredefinita  = time.Hour
+	SettimanePredefinite = 4
+)
+
+// OpzioniPrevisione indica su quali dati basare la previsione; i campi vuoti usano i valori predefiniti
+type OpzioniPrevisione struct {
+	Adesso       time.Time       // istante della previsione, predefinito: ora
+	FineServizio time.Time       // se indicato, la previsione dice se le porzioni bastano fino a quest'ora
+	Finestra     time.Duration   // periodo recente su cui misurare il consumo, predefinito: un'ora
+	Settimane    int             // settimane passate da confrontare nello stesso giorno, predefinito: 4
+	Ordini       []models.Ordine // ordini passati non presenti nello storico, es. di una stagione precedente
+}
+
+// Previsione stima quando un piatto finirà, in base al consumo recente e a quello
+// degli stessi giorni della settimana nelle settimane precedenti
+type Previsione struct {
+	Piatto       string
+	Rimanenti    float64
+	Ritmo        float64   // porzioni all'ora stimate per le prossime ore
+	RitmoRecente float64   // porzioni all'ora nella finestra recente, -1 se il servizio è appena iniziato
+	RitmoStorico float64   // porzioni all'ora nella stessa fascia delle settimane precedenti, -1 se manca lo storico
+	Settimane    int       // settimane dello storico usate per il ritmo storico
+	Esaurimento  time.Time // istante previsto di esaurimento, zero se il piatto non viene consumato
+	Fabbisogno   float64   // porzioni previste fino alla fine del servizio, se indicata
+	Basta        bool      // le porzioni bastano fino alla fine del servizio, se indicata
+}
+
+// consumo è una porzione di piatto uscita in un certo istante, dallo storico o da un ordine passato
+type consumo struct {
+	piatto   string
+	porzioni float64 // negative per gli annullamenti
+	istante  time.Time
+}
+
+// Previsioni stima l'esaurimento di tutti i piatti, a partire da quelli che finiscono prima
+func (inv *Inventory) Previsioni(opzioni OpzioniPrevisione) ([]Previsione, error) {
+	opzioni, err := opzioni.normalizza()
+	if err != nil {
+		return nil, err
+	}
+
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	consumi := inv.consumi(opzioni.Ordini)
+	previsioni := make([]Previsione, 0, len(inv.piatti))
+	for _, nome := range ordinaChiavi(inv.piatti) {
+		previsioni = append(previsioni, inv.prevedi(inv.piatti[nome], consumi, opzioni))
+	}
+
+	sort.SliceStable(previsioni, func(i, j int) bool {
+		a, b := previsioni[i].Esaurimento, previsioni[j].Esaurimento
+		if a.IsZero() != b.IsZero() {
+			return !a.IsZero()
+		}
+		return a.Before(b)
+	})
+	return previsioni, nil
+}
+
+// Prevedi stima l'esaurimento del piatto indicato
+func (inv *Inventory) Prevedi(nomePiatto string, opzioni OpzioniPrevisione) (Previsione, error) {
+	opzioni, err := opzioni.normalizza()
+	if err != nil {
+		return Previsione{}, err
+	}
+
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	piatto, exists := inv.piatti[nomePiatto]
+	if !exists {
+		return Previsione{}, fmt.Errorf("il piatto '%s' non esiste nel menu", nomePiatto)
+	}
+	return inv.prevedi(piatto, inv.consumi(opzioni.Ordini), opzioni), nil
+}
+
+func (o OpzioniPrevisione) normalizza() (OpzioniPrevisione, error) {
+	if o.Adesso.IsZero() {
+		o.Adesso = time.Now()
+	}
+	if o.Finestra == 0 {
+		o.Finestra = FinestraPredefinita
+	}
+	if o.Settimane == 0 {
+		o.Settimane = SettimanePredefinite
+	}
+	switch {
+	case o.Finestra < 0:
+		return o, fmt.Errorf("la finestra deve essere positiva (%s)", o.Finestra)
+	case o.Settimane < 0:
+		return o, fmt.Errorf("le settimane non possono essere negative (%d)", o.Settimane)
+	case !o.FineServizio.IsZero() && !o.FineServizio.After(o.Adesso):
+		return o, fmt.Errorf("la fine del servizio (%s) deve essere successiva all'istante della previsione", o.FineServizio.Format("15:04"))
+	}
+	return o, nil
+}
+
+// prevedi calcola la previsione di un piatto. Il ritmo stimato è la media tra quello recente
+// e quello storico, oppure il solo disponibile. Va chiamata con il lock già acquisito
+func (inv *Inventory) prevedi(piatto Piatto, consumi []consumo, opzioni OpzioniPrevisione) Previsione {
+	previsione := Previsione{
+		Piatto:       piatto.Nome,
+		Rimanenti:    piatto.Disponibilita,
+		RitmoRecente: -1,
+		RitmoStorico: -1,
+	}
+	if piatto.Sospeso {
+		previsione.Rimanenti = 0
+	}
+	adesso := opzioni.Adesso
+
+	// Consumo recente, dall'apertura del servizio se è più vicina dell'inizio della finestra.
+	// Se il servizio è aperto da meno di un quarto della finestra il ritmo non è affidabile
+	dal := adesso.Add(-opzioni.Finestra)
+	if apertura := inv.ultimaApertura(); apertura.After(dal) {
+		dal = apertura
+	}
+	if durata := adesso.Sub(dal); durata > 0 && durata >= opzioni.Finestra/4 {
+		previsione.RitmoRecente = sommaConsumi(consumi, piatto.Nome, dal, adesso) / durata.Hours()
+	}
+
+	// Consumo negli stessi giorni della settimana passati, nella fascia che resta da prevedere
+	orizzonte := opzioni.Finestra
+	if !opzioni.FineServizio.IsZero() {
+		orizzonte = opzioni.FineServizio.Sub(adesso)
+	}
+	inizioStorico := adesso
+	if len(inv.storico) > 0 {
+		inizioStorico = inv.storico[0].Timestamp
+	}
+	if len(consumi) > 0 && consumi[0].istante.Before(inizioStorico) {
+		inizioStorico = consumi[0].istante
+	}
+	totale := 0.0
+	for k := 1; k <= opzioni.Settimane; k++ {
+		giorno := adesso.AddDate(0, 0, -7*k)
+		if soloData(giorno).Before(soloData(inizioStorico)) {
+			break
+		}
+		totale += sommaConsumi(consumi, piatto.Nome, giorno, giorno.Add(orizzonte))
+		previsione.Settimane++
+	}
+	if previsione.Settimane > 0 {
+		previsione.RitmoStorico = totale / (float64(previsione.Settimane) * orizzonte.Hours())
+	}
+
+	switch {
+	case previsione.RitmoRecente >= 0 && previsione.RitmoStorico >= 0:
+		previsione.Ritmo = (previsione.RitmoRecente + previsione.RitmoStorico) / 2
+	case previsione.RitmoRecente >= 0:
+		previsione.Ritmo = previsione.RitmoRecente
+	case previsione.RitmoStorico >= 0:
+		previsione.Ritmo = previsione.RitmoStorico
+	}
+	previsione.Ritmo = math.Max(previsione.Ritmo, 0)
+
+	switch {
+	case previsione.Rimanenti <= 0:
+		previsione.Esaurimento = adesso
+	case previsione.Ritmo > 0:
+		ore := previsione.Rimanenti / previsione.Ritmo
+		previsione.Esaurimento = adesso.Add(time.Duration(ore * float64(time.Hour)))
+	}
+
+	if !opzioni.FineServizio.IsZero() {
+		previsione.Fabbisogno = arrotondaPorzioni(previsione.Ritmo * orizzonte.Hours())
+		previsione.Basta = previsione.Rimanenti >= previsione.Fabbisogno && previsione.Rimanenti > 0
+	}
+
+	return previsione
+}
+
+// consumi raccoglie le porzioni uscite dallo storico e dagli ordini passati, in ordine di tempo.
+// Gli ordini senza orario non indicano quando i piatti sono usciti e vengono ignorati.
+// Va chiamata con il lock già acquisito
+func (inv *Inventory) consumi(ordini []models.Ordine) []consumo {
+	var consumi []consumo
+	for _, m := range inv.storico {
+		if m.Piatto == "" || m.Ingrediente != "" {
+			continue
+		}
+		switch m.Tipo {
+		case MovimentoDecremento:
+			consumi = append(consumi, consumo{m.Piatto, m.Quantita, m.Timestamp})
+		case MovimentoAnnullamento:
+			consumi = append(consumi, consumo{m.Piatto, -m.Quantita, m.Timestamp})
+		}
+	}
+
+	for _, ordine := range ordini {
+		if ordine.Ora == "" {
+			continue
+		}
+		istante, err := time.ParseInLocation(FormatoData+" "+FormatoOra, ordine.Data+" "+ordine.Ora, time.Local)
+		if err != nil {
+			continue
+		}
+		for _, comanda := range ordine.Comande {
+			for _, p := range []*models.Piatto{comanda.Primo, comanda.Secondo, comanda.Contorno} {
+				if p == nil {
+					continue
+				}
+				porzioni := 1.0
+				if variante, ok := inv.piatti[p.Nome].Varianti[p.Variante]; ok {
+					porzioni = variante.Porzioni
+				}
+				consumi = append(consumi, consumo{p.Nome, porzioni, istante})
+			}
+		}
+	}
+
+	sort.SliceStable(consumi, func(i, j int) bool { return consumi[i].istante.Before(consumi[j].istante) })
+	return consumi
+}
+
+// sommaConsumi somma le porzioni del piatto uscite nell'intervallo [dal, al)
+func sommaConsumi(consumi []consumo, piatto string, dal, al time.Time) float64 {
+	totale := 0.0
+	for _, c := range consumi {
+		if c.piatto == piatto && !c.istante.Before(dal) && c.istante.Before(al) {
+			totale += c.porzioni
+		}
+	}
+	return totale
+}
@@ -0,0 +1,195 @@
+package inventory
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Tipi di voce della riconciliazione
+const (
+	VocePiatto      = "piatto"
+	VoceIngrediente = "ingrediente"
+)
+
+// Conteggio è l'inventario fisico contato a mano: porzioni per piatto e scorte per ingrediente
+type Conteggio struct {
+	Piatti      map[string]float64 `yaml:"piatti" json:"piatti"`
+	Ingredienti map[string]float64 `yaml:"ingredienti" json:"ingredienti"` // nell'unità di misura della dispensa
+}
+
+// RigaRiconciliazione confronta la quantità attesa di una voce con quella contata,
+// riassumendo i movimenti che hanno portato alla quantità attesa
+type RigaRiconciliazione struct {
+	Voce       string
+	Tipo       string // VocePiatto o VoceIngrediente
+	Unita      string // "porzioni" per i piatti
+	Iniziale   float64
+	Scaricato  float64 // vendite e scarti
+	Caricato   float64 // rifornimenti e annullamenti
+	Rettifiche int
+	Atteso     float64
+	Contato    float64
+	Differenza float64 // contato - atteso: negativa se manca merce
+	Ordini     []string
+}
+
+// Riconciliazione è il confronto tra l'inventario del sistema e il conteggio fisico
+type Riconciliazione struct {
+	Dal   time.Time // inizio del periodo considerato, di solito l'apertura del servizio
+	Righe []RigaRiconciliazione
+}
+
+// Discrepanze restituisce le righe in cui il conteggio non coincide con l'atteso
+func (r Riconciliazione) Discrepanze() []RigaRiconciliazione {
+	var discrepanze []RigaRiconciliazione
+	for _, riga := range r.Righe {
+		if riga.Differenza != 0 {
+			discrepanze = append(discrepanze, riga)
+		}
+	}
+	return discrepanze
+}
+
+// LoadConteggio legge un conteggio fisico da un file YAML o JSON
+func LoadConteggio(path string) (Conteggio, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Conteggio{}, fmt.Errorf("errore nella lettura del conteggio: %w", err)
+	}
+
+	var conteggio Conteggio
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.UnmarshalStrict(data, &conteggio); err != nil {
+			return Conteggio{}, fmt.Errorf("%s: YAML non valido: %w", path, err)
+		}
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&conteggio); err != nil {
+			return Conteggio{}, fmt.Errorf("%s: JSON non valido: %w", path, descriviErroreJSON(data, err))
+		}
+	default:
+		return Conteggio{}, fmt.Errorf("%s: estensione non supportata, usare .yaml, .yml o .json", path)
+	}
+
+	return conteggio, nil
+}
+
+// Riconcilia confronta le quantità attese con il conteggio fisico. Se dal è zero, il periodo
+// parte dall'ultima apertura del servizio registrata nello storico
+func (inv *Inventory) Riconcilia(conteggio Conteggio, dal time.Time) (Riconciliazione, error) {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	if dal.IsZero() {
+		dal = inv.ultimaApertura()
+	}
+	riconciliazione := Riconciliazione{Dal: dal}
+
+	for _, nome := range ordinaChiavi(conteggio.Piatti) {
+		piatto, exists := inv.piatti[nome]
+		if !exists {
+			return Riconciliazione{}, fmt.Errorf("piatti.%s: il piatto non esiste nel menu", nome)
+		}
+		riga := RigaRiconciliazione{Voce: nome, Tipo: VocePiatto, Unita: "porzioni", Atteso: piatto.Disponibilita}
+		inv.riassumi(&riga, dal, func(m Movimento) bool { return m.Ingrediente == "" && m.Piatto == nome })
+		riga.chiudi(conteggio.Piatti[nome])
+		riconciliazione.Righe = append(riconciliazione.Righe, riga)
+	}
+
+	for _, nome := range ordinaChiavi(conteggio.Ingredienti) {
+		chiave, err := inv.scorta(nome)
+		if err != nil {
+			return Riconciliazione{}, fmt.Errorf("ingredienti.%s: %w", nome, err)
+		}
+		ingrediente := inv.ingredienti[chiave]
+		riga := RigaRiconciliazione{Voce: chiave, Tipo: VoceIngrediente, Unita: ingrediente.Unita, Atteso: ingrediente.Quantita}
+		inv.riassumi(&riga, dal, func(m Movimento) bool { return m.Ingrediente == chiave })
+		riga.chiudi(conteggio.Ingredienti[nome])
+		riconciliazione.Righe = append(riconciliazione.Righe, riga)
+	}
+
+	return riconciliazione, nil
+}
+
+// ApplicaConteggio rettifica le voci discordanti al valore contato, in un'unica operazione
+func (inv *Inventory) ApplicaConteggio(riconciliazione Riconciliazione, operatore string) error {
+	var rettifiche []Movimento
+	for _, riga := range riconciliazione.Discrepanze() {
+		m := Movimento{
+			Tipo:      MovimentoRettifica,
+			Quantita:  riga.Contato,
+			Operatore: operatore,
+			Motivo:    fmt.Sprintf("conteggio fisico, differenza %+g %s", riga.Differenza, riga.Unita),
+		}
+		if riga.Tipo == VocePiatto {
+			m.Piatto = riga.Voce
+		} else {
+			m.Ingrediente = riga.Voce
+		}
+		rettifiche = append(rettifiche, m)
+	}
+	if len(rettifiche) == 0 {
+		return nil
+	}
+
+	eventi, err := inv.eseguiOperazione(rettifiche...)
+	inv.notifica(eventi)
+	return err
+}
+
+// ultimaApertura restituisce l'istante dell'ultima apertura del servizio, o zero se non è registrata.
+// Va chiamata con il lock già acquisito
+func (inv *Inventory) ultimaApertura() time.Time {
+	for i := len(inv.storico) - 1; i >= 0; i-- {
+		if inv.storico[i].Tipo == MovimentoApertura {
+			return inv.storico[i].Timestamp
+		}
+	}
+	return time.Time{}
+}
+
+// riassumi somma i movimenti della voce a partire da dal. Va chiamata con il lock già acquisito
+func (inv *Inventory) riassumi(riga *RigaRiconciliazione, dal time.Time, seleziona func(Movimento) bool) {
+	iniziale := false
+	for _, m := range inv.storico {
+		if m.Timestamp.Before(dal) || !seleziona(m) {
+			continue
+		}
+
+		switch m.Tipo {
+		case MovimentoApertura, MovimentoInserimento:
+			riga.Iniziale = m.Quantita
+			iniziale = true
+		case MovimentoDecremento, MovimentoScarto:
+			riga.Scaricato += m.Quantita
+		case MovimentoRifornimento, MovimentoAnnullamento:
+			riga.Caricato += m.Quantita
+		case MovimentoRettifica:
+			riga.Rettifiche++
+		}
+
+		if m.Ordine != "" && (len(riga.Ordini) == 0 || riga.Ordini[len(riga.Ordini)-1] != m.Ordine) {
+			riga.Ordini = append(riga.Ordini, m.Ordine)
+		}
+	}
+
+	// Senza una quantità di partenza nel periodo, la si ricava dai movimenti
+	if !iniziale && riga.Rettifiche == 0 {
+		riga.Iniziale = riga.Atteso + riga.Scaricato - riga.Caricato
+	}
+}
+
+func (riga *RigaRiconciliazione) chiudi(contato float64) {
+	riga.Contato = contato
+	riga.Differenza = math.Round((contato-riga.Atteso)*1e6) / 1e6
+}
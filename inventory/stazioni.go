@@ -0,0 +1,86 @@
+package inventory
+
+import (
+	"fmt"
+	"sort"
+)
+
+// StazionePredefinita riceve i piatti a cui non è assegnata una stazione
+const StazionePredefinita = "cucina"
+
+// Stazione è una postazione della cucina (es. griglia, pasta) con la propria
+// scorta di ingredienti e la propria stampante delle comande
+type Stazione struct {
+	Nome      string
+	Stampante string // destinazione dei ticket, es. un file o un dispositivo; vuota se la stazione non stampa
+}
+
+// SetStazione inserisce o sostituisce una stazione
+func (inv *Inventory) SetStazione(stazione Stazione) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	inv.stazioni[stazione.Nome] = stazione
+}
+
+// GetStazione restituisce la stazione indicata. La stazione predefinita esiste sempre
+func (inv *Inventory) GetStazione(nome string) (Stazione, bool) {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	if nome == "" {
+		nome = StazionePredefinita
+	}
+	stazione, exists := inv.stazioni[nome]
+	if !exists && nome == StazionePredefinita {
+		return Stazione{Nome: StazionePredefinita}, true
+	}
+	return stazione, exists
+}
+
+// Stazioni restituisce le stazioni dichiarate, in ordine alfabetico
+func (inv *Inventory) Stazioni() []Stazione {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	stazioni := make([]Stazione, 0, len(inv.stazioni))
+	for _, nome := range ordinaChiavi(inv.stazioni) {
+		stazioni = append(stazioni, inv.stazioni[nome])
+	}
+	return stazioni
+}
+
+// StazionePiatto restituisce la stazione che prepara il piatto
+func (inv *Inventory) StazionePiatto(nomePiatto string) (string, error) {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	piatto, exists := inv.piatti[nomePiatto]
+	if !exists {
+		return "", fmt.Errorf("il piatto '%s' non esiste nel menu", nomePiatto)
+	}
+	return stazionePiatto(piatto), nil
+}
+
+func stazionePiatto(piatto Piatto) string {
+	if piatto.Stazione == "" {
+		return StazionePredefinita
+	}
+	return piatto.Stazione
+}
+
+// Ingredienti restituisce la scorta della stazione indicata; la stringa vuota
+// indica la dispensa comune, non assegnata ad alcuna stazione
+func (inv *Inventory) Ingredienti(stazione string) []Ingrediente {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	var ingredienti []Ingrediente
+	for _, ingrediente := range inv.ingredienti {
+		if ingrediente.Stazione == stazione {
+			ingredienti = append(ingredienti, ingrediente)
+		}
+	}
+	sort.Slice(ingredienti, func(i, j int) bool { return ingredienti[i].Nome < ingredienti[j].Nome })
+	return ingredienti
+}
@@ -0,0 +1,498 @@
+package inventory
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	fileSnapshot = "inventario.json"
+	fileGiornale = "giornale.jsonl"
+	fileStorico  = "storico.jsonl" // tutti i movimenti, mai compattato: è la traccia per i controlli
+)
+
+// snapshot è lo stato salvato su disco: porzioni per piatto, scorte e ultimo movimento incluso
+type snapshot struct {
+	Creato        time.Time          `json:"creato"`
+	Seq           int64              `json:"seq"`
+	Disponibilita map[string]float64 `json:"disponibilita"`
+	Ingredienti   map[string]float64 `json:"ingredienti,omitempty"`
+	Sospesi       map[string]string  `json:"sospesi,omitempty"` // piatto -> motivo
+	Lotti         map[string][]Lotto `json:"lotti,omitempty"`   // ingrediente -> lotti in dispensa
+}
+
+// Store salva lo stato dell'inventario in una cartella, con uno snapshot
+// e un giornale in sola aggiunta dei movimenti successivi. Ogni movimento
+// viene copiato anche nello storico, che sopravvive alle compattazioni
+type Store struct {
+	dir      string
+	giornale *os.File
+	storico  *os.File
+	seq      int64
+	mu       sync.Mutex
+
+	storicoIndietro bool // una copia nello storico non è riuscita: va completato dal giornale
+}
+
+// OpenStore apre (creandola se necessario) la cartella che contiene lo stato
+func OpenStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("errore nella creazione della cartella di stato: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Ripristina applica all'inventario lo snapshot e i movimenti del giornale,
+// quindi collega lo store all'inventario per registrare i movimenti futuri.
+// Se non esiste uno stato salvato, lo crea a partire dall'inventario.
+func (s *Store) Ripristina(inv *Inventory) error {
+	// L'ordine dei lock è sempre inventario e poi store, lo stesso usato da Registra
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snap, err := s.leggiSnapshot()
+	if os.IsNotExist(err) {
+		return s.resetLocked(inv)
+	}
+	if err != nil {
+		return err
+	}
+
+	for nome, disponibilita := range snap.Disponibilita {
+		if piatto, exists := inv.piatti[nome]; exists {
+			piatto.Disponibilita = disponibilita
+			if motivo, sospeso := snap.Sospesi[nome]; sospeso {
+				piatto.Sospeso = true
+				piatto.MotivoSospensione = motivo
+			}
+			inv.piatti[nome] = piatto
+		}
+	}
+	for nome, quantita := range snap.Ingredienti {
+		// Gli snapshot scritti prima delle scorte per stazione indicano solo il nome dell'ingrediente
+		if chiave, err := inv.scorta(nome); err == nil {
+			ingrediente := inv.ingredienti[chiave]
+			ingrediente.Quantita = quantita
+			ingrediente.Lotti = snap.Lotti[nome]
+			inv.ingredienti[chiave] = ingrediente
+		}
+	}
+
+	movimenti, validi, err := s.leggiMovimenti(fileGiornale)
+	if err != nil {
+		return err
+	}
+
+	s.seq = snap.Seq
+	for _, m := range movimenti {
+		// I movimenti già inclusi nello snapshot vengono ignorati: può succedere
+		// se l'esecuzione si è interrotta tra la scrittura dello snapshot e lo svuotamento del giornale
+		if m.Seq <= snap.Seq {
+			continue
+		}
+		inv.applica(m)
+		s.seq = m.Seq
+	}
+
+	if err := s.apriGiornale(validi); err != nil {
+		return err
+	}
+
+	// Lo storico su disco sostituisce quello in memoria, che contiene solo il caricamento del menu
+	storico, err := s.apriStorico(movimenti)
+	if err != nil {
+		return err
+	}
+	inv.storico = storico
+	inv.registro = s
+
+	return nil
+}
+
+// Reset scrive un nuovo snapshot con le porzioni attuali dell'inventario e
+// svuota il giornale. Va usato all'inizio del servizio.
+func (s *Store) Reset(inv *Inventory) error {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.resetLocked(inv)
+}
+
+// resetLocked va chiamata con il lock dell'inventario e dello store già acquisiti
+func (s *Store) resetLocked(inv *Inventory) error {
+	// I movimenti del giornale non ancora copiati nello storico vanno salvati prima di svuotarlo
+	giornale, _, err := s.leggiMovimenti(fileGiornale)
+	if err != nil {
+		return err
+	}
+	storico, err := s.apriStorico(giornale)
+	if err != nil {
+		return err
+	}
+
+	// Le quantità di partenza restano nello storico, come base per la riconciliazione di fine servizio
+	var apertura []Movimento
+	adesso := time.Now()
+	for _, nome := range ordinaChiavi(inv.piatti) {
+		apertura = append(apertura, Movimento{Tipo: MovimentoApertura, Piatto: nome, Quantita: inv.piatti[nome].Disponibilita, Timestamp: adesso})
+	}
+	for _, nome := range ordinaChiavi(inv.ingredienti) {
+		apertura = append(apertura, Movimento{Tipo: MovimentoApertura, Ingrediente: nome, Quantita: inv.ingredienti[nome].Quantita, Timestamp: adesso})
+	}
+	apertura, err = s.scrivi(s.storico, apertura)
+	if err != nil {
+		return fmt.Errorf("errore nella scrittura dello storico: %w", err)
+	}
+
+	if err := s.compatta(inv); err != nil {
+		return err
+	}
+	inv.storico = append(storico, apertura...)
+	inv.registro = s
+
+	return nil
+}
+
+// Snapshot salva lo stato attuale e svuota il giornale, che altrimenti crescerebbe a ogni movimento
+func (s *Store) Snapshot(inv *Inventory) error {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.compatta(inv)
+}
+
+// compatta scrive lo snapshot e riparte con un giornale vuoto.
+// Va chiamata con il lock dello store e dell'inventario già acquisiti
+func (s *Store) compatta(inv *Inventory) error {
+	// Il giornale è l'unica copia dei movimenti che mancano allo storico: finché non sono
+	// stati copiati non può essere svuotato
+	if s.storicoIndietro {
+		if err := s.allineaStorico(); err != nil {
+			return fmt.Errorf("lo storico non contiene tutti i movimenti, il giornale non viene svuotato: %w", err)
+		}
+	}
+
+	snap := snapshot{
+		Creato:        time.Now(),
+		Seq:           s.seq,
+		Disponibilita: make(map[string]float64, len(inv.piatti)),
+		Ingredienti:   make(map[string]float64, len(inv.ingredienti)),
+		Sospesi:       make(map[string]string),
+		Lotti:         make(map[string][]Lotto),
+	}
+	for nome, piatto := range inv.piatti {
+		snap.Disponibilita[nome] = piatto.Disponibilita
+		if piatto.Sospeso {
+			snap.Sospesi[nome] = piatto.MotivoSospensione
+		}
+	}
+	for nome, ingrediente := range inv.ingredienti {
+		snap.Ingredienti[nome] = ingrediente.Quantita
+		if len(ingrediente.Lotti) > 0 {
+			snap.Lotti[nome] = ingrediente.Lotti
+		}
+	}
+
+	if err := s.scriviSnapshot(snap); err != nil {
+		return err
+	}
+
+	return s.apriGiornale(0)
+}
+
+// Registra aggiunge i movimenti al giornale con una sola scrittura e li rende persistenti su disco,
+// quindi li copia nello storico. Ai movimenti viene assegnato il numero progressivo
+func (s *Store) Registra(movimenti ...Movimento) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.giornale == nil {
+		return fmt.Errorf("giornale non aperto")
+	}
+
+	numerati, err := s.scrivi(s.giornale, movimenti)
+	if err != nil {
+		return fmt.Errorf("errore nella scrittura del giornale: %w", err)
+	}
+	copy(movimenti, numerati)
+
+	// Il movimento è già persistente nel giornale, quindi va applicato anche se la copia
+	// nello storico non riesce: lo storico viene completato dal giornale prima della prossima
+	// copia, così da non lasciare buchi, e il giornale non viene svuotato finché non lo è
+	if s.storicoIndietro {
+		s.allineaStorico()
+	} else if _, err := s.scriviStorico(numerati); err != nil {
+		s.storicoIndietro = true
+	}
+
+	return nil
+}
+
+// allineaStorico copia nello storico i movimenti del giornale che ancora gli mancano.
+// Va chiamata con il lock dello store già acquisito
+func (s *Store) allineaStorico() error {
+	giornale, _, err := s.leggiMovimenti(fileGiornale)
+	if err != nil {
+		return err
+	}
+	_, err = s.apriStorico(giornale)
+	return err
+}
+
+// Storico legge dallo storico su disco i movimenti che corrispondono al filtro
+func (s *Store) Storico(filtro FiltroStorico) ([]Movimento, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	movimenti, _, err := s.leggiMovimenti(fileStorico)
+	if err != nil {
+		return nil, err
+	}
+
+	var risultato []Movimento
+	for _, m := range movimenti {
+		if filtro.corrisponde(m) {
+			risultato = append(risultato, m)
+		}
+	}
+	return risultato, nil
+}
+
+// scrivi numera i movimenti e li aggiunge al file con una sola scrittura, rendendoli persistenti.
+// Va chiamata con il lock dello store già acquisito
+func (s *Store) scrivi(file *os.File, movimenti []Movimento) ([]Movimento, error) {
+	numerati := make([]Movimento, len(movimenti))
+	var buf []byte
+	seq := s.seq
+	for i, m := range movimenti {
+		seq++
+		m.Seq = seq
+		data, err := json.Marshal(m)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(append(buf, data...), '\n')
+		numerati[i] = m
+	}
+
+	if _, err := file.Write(buf); err != nil {
+		return nil, err
+	}
+	if err := file.Sync(); err != nil {
+		return nil, err
+	}
+	s.seq = seq
+
+	return numerati, nil
+}
+
+// scriviStorico aggiunge allo storico movimenti già numerati
+func (s *Store) scriviStorico(movimenti []Movimento) ([]Movimento, error) {
+	if s.storico == nil {
+		return nil, fmt.Errorf("storico non aperto")
+	}
+
+	var buf []byte
+	for _, m := range movimenti {
+		data, err := json.Marshal(m)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(append(buf, data...), '\n')
+	}
+	if _, err := s.storico.Write(buf); err != nil {
+		return nil, err
+	}
+	return movimenti, s.storico.Sync()
+}
+
+// apriStorico legge lo storico su disco, lo completa con i movimenti del giornale che non
+// vi erano ancora stati copiati e lo apre in aggiunta. Restituisce lo storico completo.
+// Va chiamata con il lock dello store già acquisito
+func (s *Store) apriStorico(giornale []Movimento) ([]Movimento, error) {
+	storico, validi, err := s.leggiMovimenti(fileStorico)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.storico != nil {
+		s.storico.Close()
+		s.storico = nil
+	}
+	file, err := apriInAggiunta(filepath.Join(s.dir, fileStorico), validi)
+	if err != nil {
+		return nil, fmt.Errorf("errore nell'apertura dello storico: %w", err)
+	}
+	s.storico = file
+
+	var ultimo int64
+	if len(storico) > 0 {
+		ultimo = storico[len(storico)-1].Seq
+	}
+	var mancanti []Movimento
+	for _, m := range giornale {
+		if m.Seq > ultimo {
+			mancanti = append(mancanti, m)
+		}
+	}
+	if len(mancanti) > 0 {
+		if _, err := s.scriviStorico(mancanti); err != nil {
+			return nil, fmt.Errorf("errore nella scrittura dello storico: %w", err)
+		}
+		storico = append(storico, mancanti...)
+		ultimo = mancanti[len(mancanti)-1].Seq
+	}
+
+	// Dopo un reset la numerazione prosegue da quella dello storico
+	s.seq = max(s.seq, ultimo)
+	s.storicoIndietro = false
+
+	return storico, nil
+}
+
+// Close chiude il giornale
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.giornale == nil {
+		return nil
+	}
+	err := s.giornale.Close()
+	s.giornale = nil
+	if s.storico != nil {
+		if errStorico := s.storico.Close(); err == nil {
+			err = errStorico
+		}
+		s.storico = nil
+	}
+	return err
+}
+
+func (s *Store) leggiSnapshot() (snapshot, error) {
+	var snap snapshot
+
+	data, err := os.ReadFile(filepath.Join(s.dir, fileSnapshot))
+	if err != nil {
+		return snap, err
+	}
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return snap, fmt.Errorf("snapshot dell'inventario danneggiato: %w", err)
+	}
+
+	return snap, nil
+}
+
+// scriviSnapshot scrive prima un file temporaneo e poi lo rinomina, così un'interruzione
+// a metà scrittura lascia intatto lo snapshot precedente
+func (s *Store) scriviSnapshot(snap snapshot) error {
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(s.dir, fileSnapshot+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("errore nella scrittura dello snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("errore nella scrittura dello snapshot: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("errore nella scrittura dello snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("errore nella scrittura dello snapshot: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), filepath.Join(s.dir, fileSnapshot)); err != nil {
+		return fmt.Errorf("errore nella scrittura dello snapshot: %w", err)
+	}
+
+	return nil
+}
+
+// leggiMovimenti restituisce i movimenti registrati nel giornale o nello storico e la lunghezza
+// in byte della parte valida. Un'ultima riga incompleta (scrittura interrotta) viene scartata.
+func (s *Store) leggiMovimenti(nome string) ([]Movimento, int64, error) {
+	file, err := os.Open(filepath.Join(s.dir, nome))
+	if os.IsNotExist(err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("errore nella lettura di %s: %w", nome, err)
+	}
+	defer file.Close()
+
+	var movimenti []Movimento
+	var validi int64
+	reader := bufio.NewReader(file)
+
+	for riga := 1; ; riga++ {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// Riga senza terminatore: la scrittura si è interrotta a metà
+			return movimenti, validi, nil
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("errore nella lettura di %s: %w", nome, err)
+		}
+
+		var m Movimento
+		if err := json.Unmarshal(line, &m); err != nil {
+			return nil, 0, fmt.Errorf("%s danneggiato alla riga %d: %w", nome, riga, err)
+		}
+		movimenti = append(movimenti, m)
+		validi += int64(len(line))
+	}
+}
+
+// apriGiornale apre il giornale in aggiunta, troncandolo alla lunghezza indicata
+// per eliminare eventuali righe incomplete
+func (s *Store) apriGiornale(lunghezza int64) error {
+	if s.giornale != nil {
+		s.giornale.Close()
+		s.giornale = nil
+	}
+
+	file, err := apriInAggiunta(filepath.Join(s.dir, fileGiornale), lunghezza)
+	if err != nil {
+		return fmt.Errorf("errore nell'apertura del giornale: %w", err)
+	}
+
+	s.giornale = file
+	return nil
+}
+
+// apriInAggiunta apre il file per aggiungere righe, dopo averlo troncato alla lunghezza indicata
+func apriInAggiunta(path string, lunghezza int64) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := file.Truncate(lunghezza); err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err := file.Seek(lunghezza, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
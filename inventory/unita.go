@@ -0,0 +1,74 @@
+package inventory
+
+import (
+	"fmt"
+
+	"github.com/branila/restaurant-protocol/units"
+)
+
+// inUnitaBase riporta la scorta, i lotti, il par e la confezione all'unità base della
+// grandezza misurata (es. kg -> g), in cui l'inventario tiene tutte le scorte
+func (i Ingrediente) inUnitaBase() (Ingrediente, error) {
+	unita, err := units.ParseUnita(i.Unita)
+	if err != nil {
+		return Ingrediente{}, fmt.Errorf("ingrediente '%s': %w", i.Nome, err)
+	}
+	base := unita.Base()
+
+	converti := func(valore float64) float64 {
+		q, _ := units.Quantita{Valore: valore, Unita: unita}.In(base)
+		return q.Valore
+	}
+	i.Unita = string(base)
+	i.Quantita = converti(i.Quantita)
+	i.Par = converti(i.Par)
+	i.Confezione = converti(i.Confezione)
+
+	if len(i.Lotti) > 0 {
+		lotti := make([]Lotto, len(i.Lotti))
+		for j, lotto := range i.Lotti {
+			lotto.Quantita = converti(lotto.Quantita)
+			lotti[j] = lotto
+		}
+		i.Lotti = lotti
+	}
+
+	return i, nil
+}
+
+// QuantitaIngrediente converte una quantità nell'unità in cui è tenuta la scorta dell'ingrediente,
+// ad esempio 2 kg di pasta in 2000 g. Le unità di grandezze diverse sono rifiutate
+func (inv *Inventory) QuantitaIngrediente(nomeIngrediente string, quantita units.Quantita) (float64, error) {
+	inv.mu.RLock()
+	chiave, err := inv.scorta(nomeIngrediente)
+	ingrediente := inv.ingredienti[chiave]
+	inv.mu.RUnlock()
+
+	if err != nil {
+		return 0, err
+	}
+	convertita, err := quantita.In(units.Unita(ingrediente.Unita))
+	if err != nil {
+		return 0, fmt.Errorf("la scorta di '%s' è in %s: %w", nomeIngrediente, ingrediente.Unita, err)
+	}
+	return convertita.Valore, nil
+}
+
+// RifornisciIngredienteIn aggiunge scorta a un ingrediente indicando la quantità con la sua unità
+// (es. 2 kg), convertita in quella della dispensa
+func (inv *Inventory) RifornisciIngredienteIn(nomeIngrediente string, quantita units.Quantita, operatore string, motivo string) error {
+	convertita, err := inv.QuantitaIngrediente(nomeIngrediente, quantita)
+	if err != nil {
+		return err
+	}
+	return inv.RifornisciIngrediente(nomeIngrediente, convertita, operatore, motivo)
+}
+
+// RettificaIngredienteIn imposta la scorta di un ingrediente al valore contato, con la sua unità
+func (inv *Inventory) RettificaIngredienteIn(nomeIngrediente string, quantita units.Quantita, operatore string, motivo string) error {
+	convertita, err := inv.QuantitaIngrediente(nomeIngrediente, quantita)
+	if err != nil {
+		return err
+	}
+	return inv.RettificaIngrediente(nomeIngrediente, convertita, operatore, motivo)
+}
@@ -0,0 +1,59 @@
+package inventory
+
+import (
+	"math"
+
+	"github.com/branila/restaurant-protocol/errors"
+	"github.com/branila/restaurant-protocol/money"
+)
+
+// Variante è un formato alternativo di un piatto, come la mezza porzione o la porzione bimbi
+type Variante struct {
+	Nome              string  // es. "mezza"
+	PercentualePrezzo int     // prezzo in percentuale di quello del piatto, es. 60
+	Porzioni          float64 // porzioni scalate dall'inventario, es. 0.5
+}
+
+// VerificaVariante controlla che il piatto preveda la variante indicata.
+// La variante vuota indica la porzione normale ed è sempre ammessa
+func (inv *Inventory) VerificaVariante(nomePiatto string, variante string) error {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	piatto, exists := inv.piatti[nomePiatto]
+	if !exists {
+		return errors.NewPiattoInesistenteError(nomePiatto)
+	}
+
+	if _, err := piatto.variante(variante); err != nil {
+		return err
+	}
+	return nil
+}
+
+// PrezzoVariante restituisce il prezzo del piatto nella variante indicata
+func (p Piatto) PrezzoVariante(variante string) (money.Importo, error) {
+	v, err := p.variante(variante)
+	if err != nil {
+		return 0, err
+	}
+	return p.Prezzo.Percentuale(v.PercentualePrezzo), nil
+}
+
+// variante restituisce la variante indicata; quella vuota è la porzione normale
+func (p Piatto) variante(nome string) (Variante, error) {
+	if nome == "" {
+		return Variante{PercentualePrezzo: 100, Porzioni: 1}, nil
+	}
+
+	v, exists := p.Varianti[nome]
+	if !exists {
+		return Variante{}, errors.NewVarianteNonValidaError(p.Nome, nome, ordinaChiavi(p.Varianti))
+	}
+	return v, nil
+}
+
+// arrotondaPorzioni elimina gli errori di arrotondamento accumulati sommando frazioni di porzione
+func arrotondaPorzioni(porzioni float64) float64 {
+	return math.Round(porzioni*1e6) / 1e6
+}
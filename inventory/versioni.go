@@ -0,0 +1,247 @@
+package inventory
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/branila/restaurant-protocol/errors"
+	"github.com/branila/restaurant-protocol/models"
+	"github.com/branila/restaurant-protocol/money"
+)
+
+// VersioneCorrente indica il menu dei piatti presenti nell'inventario, invece di una versione registrata
+const VersioneCorrente = "corrente"
+
+// VersioneMenu è il menu in vigore da una certa data fino alla versione successiva: portate,
+// modifiche consentite, opzioni e varianti dei piatti. Le porzioni disponibili e le scorte
+// restano sempre quelle dell'inventario
+type VersioneMenu struct {
+	Nome   string
+	Dal    time.Time
+	Piatti map[string]Piatto
+}
+
+// DiffPiatto descrive come è cambiato un piatto presente in entrambe le versioni
+type DiffPiatto struct {
+	Piatto         string
+	PortateDa      []string      // portate nella prima versione, se sono cambiate
+	PortateA       []string      // portate nella seconda versione, se sono cambiate
+	PrezzoDa       money.Importo // uguale a PrezzoA se il prezzo non è cambiato
+	PrezzoA        money.Importo
+	ModificheNuove []models.Modifica // consentite solo nella seconda versione
+	ModificheTolte []models.Modifica // consentite solo nella prima versione
+}
+
+// DiffMenu raccoglie le differenze tra due versioni del menu
+type DiffMenu struct {
+	Da, A      string
+	Nuovi      []string // piatti presenti solo nella seconda versione
+	Rimossi    []string // piatti presenti solo nella prima versione
+	Modificati []DiffPiatto
+}
+
+// SetVersione registra una versione del menu; quella con la stessa data viene sostituita.
+// Se il nome manca viene usata la data
+func (inv *Inventory) SetVersione(versione VersioneMenu) error {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	return inv.setVersione(versione)
+}
+
+// FissaVersione registra i piatti attuali dell'inventario come versione del menu in vigore dal giorno indicato
+func (inv *Inventory) FissaVersione(nome string, dal time.Time) error {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+
+	return inv.setVersione(VersioneMenu{Nome: nome, Dal: dal, Piatti: inv.piatti})
+}
+
+// setVersione va chiamata con il lock già acquisito
+func (inv *Inventory) setVersione(versione VersioneMenu) error {
+	if versione.Dal.IsZero() {
+		return fmt.Errorf("la data di inizio della versione è obbligatoria")
+	}
+	if len(versione.Piatti) == 0 {
+		return fmt.Errorf("la versione del %s non contiene piatti", versione.Dal.Format(FormatoData))
+	}
+	versione.Dal = soloData(versione.Dal)
+	if versione.Nome == "" {
+		versione.Nome = versione.Dal.Format(FormatoData)
+	}
+	if versione.Nome == VersioneCorrente {
+		return fmt.Errorf("il nome '%s' è riservato al menu attuale", VersioneCorrente)
+	}
+	versione.Piatti = clonaPiatti(versione.Piatti)
+
+	for i, v := range inv.versioni {
+		if v.Nome == versione.Nome && !v.Dal.Equal(versione.Dal) {
+			return fmt.Errorf("esiste già una versione '%s' in vigore dal %s", v.Nome, v.Dal.Format(FormatoData))
+		}
+		if v.Dal.Equal(versione.Dal) {
+			inv.versioni[i] = versione
+			return nil
+		}
+	}
+
+	inv.versioni = append(inv.versioni, versione)
+	sort.Slice(inv.versioni, func(i, j int) bool { return inv.versioni[i].Dal.Before(inv.versioni[j].Dal) })
+	return nil
+}
+
+// Versioni restituisce le versioni registrate del menu, dalla più vecchia
+func (inv *Inventory) Versioni() []VersioneMenu {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	return slices.Clone(inv.versioni)
+}
+
+// Versione cerca una versione per nome o per data di inizio (DD/MM/YYYY).
+// VersioneCorrente restituisce i piatti attuali dell'inventario
+func (inv *Inventory) Versione(riferimento string) (VersioneMenu, error) {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	if riferimento == VersioneCorrente {
+		return VersioneMenu{Nome: VersioneCorrente, Piatti: clonaPiatti(inv.piatti)}, nil
+	}
+	for _, v := range inv.versioni {
+		if v.Nome == riferimento || v.Dal.Format(FormatoData) == riferimento {
+			return v, nil
+		}
+	}
+	return VersioneMenu{}, fmt.Errorf("la versione '%s' del menu non esiste", riferimento)
+}
+
+// VersioneAl restituisce la versione in vigore nel giorno indicato: l'ultima iniziata entro quel giorno.
+// Restituisce false se non ci sono versioni o se il giorno precede la prima
+func (inv *Inventory) VersioneAl(giorno time.Time) (VersioneMenu, bool) {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	return inv.versioneAl(giorno)
+}
+
+// versioneAl va chiamata con il lock già acquisito
+func (inv *Inventory) versioneAl(giorno time.Time) (VersioneMenu, bool) {
+	giorno = soloData(giorno)
+	for i := len(inv.versioni) - 1; i >= 0; i-- {
+		if !inv.versioni[i].Dal.After(giorno) {
+			return inv.versioni[i], true
+		}
+	}
+	return VersioneMenu{}, false
+}
+
+// VersioneOrdine restituisce la versione del menu in vigore alla data dell'ordine
+func (inv *Inventory) VersioneOrdine(ordine models.Ordine) (VersioneMenu, bool, error) {
+	giorno, err := time.Parse(FormatoData, ordine.Data)
+	if err != nil {
+		return VersioneMenu{}, false, errors.NewFormatoDataError(ordine.Data)
+	}
+	versione, exists := inv.VersioneAl(giorno)
+	return versione, exists, nil
+}
+
+// MenuAl restituisce un inventario con i piatti della versione in vigore nel giorno indicato,
+// su cui verificare portate, modifiche, opzioni e varianti di un ordine di quel giorno.
+// Non ha ingredienti né storico: disponibilità e scorte vanno verificate sull'inventario.
+// Senza una versione in vigore restituisce l'inventario stesso
+func (inv *Inventory) MenuAl(giorno time.Time) *Inventory {
+	inv.mu.RLock()
+	defer inv.mu.RUnlock()
+
+	versione, exists := inv.versioneAl(giorno)
+	if !exists {
+		return inv
+	}
+
+	menu := New()
+	menu.piatti = clonaPiatti(versione.Piatti)
+	for voce, categorie := range inv.categorie {
+		menu.categorie[voce] = slices.Clone(categorie)
+	}
+	menu.coperto = inv.coperto
+	return menu
+}
+
+// ConfrontaVersioni elenca i piatti nuovi, quelli rimossi e quelli cambiati passando da una versione all'altra
+func ConfrontaVersioni(da, a VersioneMenu) DiffMenu {
+	diff := DiffMenu{Da: da.Nome, A: a.Nome}
+
+	for _, nome := range ordinaChiavi(a.Piatti) {
+		if _, exists := da.Piatti[nome]; !exists {
+			diff.Nuovi = append(diff.Nuovi, nome)
+		}
+	}
+	for _, nome := range ordinaChiavi(da.Piatti) {
+		prima := da.Piatti[nome]
+		dopo, exists := a.Piatti[nome]
+		if !exists {
+			diff.Rimossi = append(diff.Rimossi, nome)
+			continue
+		}
+
+		cambiamento := DiffPiatto{
+			Piatto:         nome,
+			PrezzoDa:       prima.Prezzo,
+			PrezzoA:        dopo.Prezzo,
+			ModificheNuove: modificheEscluse(dopo, prima),
+			ModificheTolte: modificheEscluse(prima, dopo),
+		}
+		if !slices.Equal(prima.Portate(), dopo.Portate()) {
+			cambiamento.PortateDa, cambiamento.PortateA = prima.Portate(), dopo.Portate()
+		}
+
+		if cambiamento.PortateDa != nil || cambiamento.PortateA != nil || cambiamento.PrezzoDa != cambiamento.PrezzoA ||
+			len(cambiamento.ModificheNuove) > 0 || len(cambiamento.ModificheTolte) > 0 {
+			diff.Modificati = append(diff.Modificati, cambiamento)
+		}
+	}
+
+	return diff
+}
+
+// Vuoto indica se le due versioni confrontate hanno gli stessi piatti
+func (d DiffMenu) Vuoto() bool {
+	return len(d.Nuovi) == 0 && len(d.Rimossi) == 0 && len(d.Modificati) == 0
+}
+
+// modificheEscluse elenca le modifiche consentite dal piatto p che l'altro non consente nello stesso verso
+func modificheEscluse(p, altro Piatto) []models.Modifica {
+	var modifiche []models.Modifica
+	for _, voce := range ordinaChiavi(p.ModificheConsentite) {
+		aggiunta := p.ModificheConsentite[voce]
+		if consentita, exists := altro.ModificheConsentite[voce]; exists && consentita == aggiunta {
+			continue
+		}
+		modifica := models.Modifica{Tipo: "-", Voce: voce}
+		if aggiunta {
+			modifica.Tipo = "+"
+		}
+		modifiche = append(modifiche, modifica)
+	}
+	return modifiche
+}
+
+// clonaPiatti copia i piatti con le loro mappe, che l'inventario può modificare sul posto (es. AddOpzione)
+func clonaPiatti(piatti map[string]Piatto) map[string]Piatto {
+	copia := make(map[string]Piatto, len(piatti))
+	for nome, piatto := range piatti {
+		piatto.AltrePortate = slices.Clone(piatto.AltrePortate)
+		piatto.Supplementi = maps.Clone(piatto.Supplementi)
+		piatto.ModificheConsentite = maps.Clone(piatto.ModificheConsentite)
+		piatto.Opzioni = maps.Clone(piatto.Opzioni)
+		piatto.Varianti = maps.Clone(piatto.Varianti)
+		piatto.Ricetta = maps.Clone(piatto.Ricetta)
+		piatto.Aggiunte = maps.Clone(piatto.Aggiunte)
+		piatto.Allergeni = slices.Clone(piatto.Allergeni)
+		piatto.Tag = slices.Clone(piatto.Tag)
+		copia[nome] = piatto
+	}
+	return copia
+}
@@ -2,19 +2,92 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"log"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/branila/restaurant-protocol/billing"
 	"github.com/branila/restaurant-protocol/converter"
 	"github.com/branila/restaurant-protocol/errors"
 	"github.com/branila/restaurant-protocol/formatter"
+	"github.com/branila/restaurant-protocol/inventory"
+	"github.com/branila/restaurant-protocol/models"
 	"github.com/branila/restaurant-protocol/parser"
+	"github.com/branila/restaurant-protocol/routing"
+	"github.com/branila/restaurant-protocol/units"
 )
 
 func main() {
-	// Inizializza il parser
-	parser.Init()
+	menuFile := flag.String("menu", "", "file YAML o JSON con il menu e le porzioni disponibili")
+	statoDir := flag.String("stato", "", "cartella in cui salvare le porzioni rimaste tra un'esecuzione e l'altra")
+	reset := flag.Bool("reset", false, "riparte dalle porzioni del menu (inizio del servizio)")
+	dividi := flag.String("dividi", "", "divide il conto per \"comanda\", per \"posti\" oppure in N parti uguali (es. 3)")
+	eventiFile := flag.String("eventi", "", "file in cui registrare gli avvisi sulle scorte (predefinito: stderr)")
+	stampa := flag.Bool("stampa", false, "invia i ticket alle stampanti delle stazioni")
+	flag.Parse()
+
+	// Inizializza il parser con il menu indicato o con quello predefinito
+	if *menuFile != "" {
+		inv, err := inventory.LoadFromFile(*menuFile)
+		if err != nil {
+			fmt.Printf("Errore nel caricamento del menu: %v\n", err)
+			return
+		}
+		parser.Inventario = inv
+	} else {
+		parser.Init()
+	}
+
+	// Inoltra gli avvisi sulle scorte al log, lontano dall'output dell'ordine
+	eventiLog, err := apriLogEventi(*eventiFile)
+	if err != nil {
+		fmt.Printf("Errore nell'apertura del log degli eventi: %v\n", err)
+		return
+	}
+	parser.Inventario.Sottoscrivi(func(evento inventory.Evento) {
+		eventiLog.Println(evento)
+	})
+
+	// Ripristina le porzioni rimaste dalle esecuzioni precedenti
+	if *statoDir != "" {
+		store, err := inventory.OpenStore(*statoDir)
+		if err != nil {
+			fmt.Printf("Errore nell'apertura dello stato: %v\n", err)
+			return
+		}
+		defer store.Close()
+
+		if *reset {
+			err = store.Reset(parser.Inventario)
+		} else {
+			err = store.Ripristina(parser.Inventario)
+		}
+		if err != nil {
+			fmt.Printf("Errore nel ripristino dello stato: %v\n", err)
+			return
+		}
+		// Salva lo stato all'uscita; il giornale resta valido se il salvataggio non riesce
+		defer func() {
+			if err := store.Snapshot(parser.Inventario); err != nil {
+				fmt.Printf("Errore nel salvataggio dello stato: %v\n", err)
+			}
+		}()
+	}
+
+	// I comandi consultano o aggiornano l'inventario invece di elaborare un ordine
+	if flag.NArg() > 0 {
+		if err := eseguiComando(flag.Arg(0), flag.Args()[1:]); err != nil {
+			fmt.Printf("Errore nel comando %s: %v\n", flag.Arg(0), err)
+		}
+		return
+	}
 
 	// Legge il file di input
 	lines, err := readInputFile("ordine.txt")
@@ -41,6 +114,44 @@ func main() {
 	output := formatter.FormatOrdine(ordine)
 	fmt.Println(output)
 
+	// Per gli ordini con i posti indicati, stampa anche i piatti raggruppati per ospite
+	if ordine.HaPosti() {
+		fmt.Println(formatter.FormatPerPosto(ordine))
+	}
+
+	// Divide l'ordine tra le stazioni della cucina
+	tickets, err := routing.Instrada(ordine, parser.Inventario)
+	if err != nil {
+		fmt.Printf("Errore nell'instradamento dell'ordine: %v\n", err)
+		return
+	}
+	for _, ticket := range tickets {
+		fmt.Println(formatter.FormatTicket(ticket))
+		if *stampa {
+			if err := stampaTicket(ticket); err != nil {
+				fmt.Printf("Errore nella stampa del ticket per la stazione %s: %v\n", ticket.Stazione, err)
+			}
+		}
+	}
+
+	// Calcola e stampa il conto
+	conto, err := billing.Calcola(ordine, parser.Inventario, billing.AliquotaIVARistorazione)
+	if err != nil {
+		handleError(err)
+		return
+	}
+	fmt.Println(formatter.FormatConto(conto))
+
+	// Divide il conto, se richiesto
+	if *dividi != "" {
+		quote, err := dividiConto(conto, *dividi)
+		if err != nil {
+			fmt.Printf("Errore nella divisione del conto: %v\n", err)
+			return
+		}
+		fmt.Println(formatter.FormatQuote(quote))
+	}
+
 	// Converte l'ordine in altri formati
 	jsonOutput, err := converter.ToJSON(ordine)
 	if err != nil {
@@ -76,6 +187,605 @@ func readInputFile(filename string) ([]string, error) {
 	return lines, nil
 }
 
+// accoda il ticket al file della stampante della stazione, se ne ha una
+func stampaTicket(ticket routing.Ticket) error {
+	if ticket.Stampante == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(ticket.Stampante), 0o755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(ticket.Stampante, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = fmt.Fprintln(file, formatter.FormatTicket(ticket))
+	return err
+}
+
+// divide il conto nel modo indicato dall'opzione -dividi
+func dividiConto(conto billing.Conto, modo string) ([]billing.Quota, error) {
+	switch modo {
+	case "comanda":
+		return billing.DividiPerComanda(conto)
+	case "posti":
+		return billing.DividiPerPosti(conto, nil)
+	}
+
+	parti, err := strconv.Atoi(modo)
+	if err != nil {
+		return nil, fmt.Errorf("modo %q non valido, usare \"comanda\", \"posti\" o un numero di parti", modo)
+	}
+	return billing.DividiInParti(conto, parti)
+}
+
+// esegue il comando indicato dopo le opzioni globali
+func eseguiComando(comando string, args []string) error {
+	switch comando {
+	case "menu":
+		return stampaMenu(args)
+	case "storico":
+		return stampaStorico(args)
+	case "riconcilia":
+		return riconcilia(args)
+	case "esporta":
+		return esporta(args)
+	case "importa":
+		return importa(args)
+	case "prevedi":
+		return prevedi(args)
+	case "capacita":
+		return capacita(args)
+	case "acquisti":
+		return acquisti(args)
+	case "rifornisci":
+		return rifornisci(args)
+	case "versioni":
+		return versioni(args)
+	case "verifica":
+		return verifica(args)
+	default:
+		return fmt.Errorf("comando sconosciuto, usare menu, storico, riconcilia, esporta, importa, prevedi, capacita, acquisti, rifornisci, versioni o verifica")
+	}
+}
+
+// stampa i movimenti dell'inventario secondo le opzioni del comando "storico"
+func stampaStorico(args []string) error {
+	comando := flag.NewFlagSet("storico", flag.ContinueOnError)
+	piatto := comando.String("piatto", "", "solo i movimenti del piatto indicato")
+	ingrediente := comando.String("ingrediente", "", "solo i movimenti dell'ingrediente indicato")
+	tipo := comando.String("tipo", "", "solo i movimenti del tipo indicato (es. decremento, rifornimento)")
+	ordine := comando.String("ordine", "", "solo i movimenti dell'ordine indicato (es. \"tavolo 1 del 13/11/2025\")")
+	if err := comando.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return err
+	}
+
+	fmt.Print(formatter.FormatStorico(parser.Inventario.Storico(inventory.FiltroStorico{
+		Piatto:      *piatto,
+		Ingrediente: *ingrediente,
+		Tipo:        *tipo,
+		Ordine:      *ordine,
+	})))
+	return nil
+}
+
+// confronta l'inventario con il conteggio fisico indicato e, se richiesto, lo rettifica
+func riconcilia(args []string) error {
+	comando := flag.NewFlagSet("riconcilia", flag.ContinueOnError)
+	dal := comando.String("dal", "", "inizio del periodo, DD/MM/YYYY (predefinito: ultima apertura del servizio)")
+	applica := comando.Bool("applica", false, "rettifica l'inventario ai valori contati")
+	operatore := comando.String("operatore", "", "chi ha eseguito il conteggio, obbligatorio con -applica")
+	if err := comando.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return err
+	}
+	if comando.NArg() != 1 {
+		return fmt.Errorf("indicare il file YAML o JSON con il conteggio fisico")
+	}
+
+	var inizio time.Time
+	if *dal != "" {
+		var err error
+		if inizio, err = time.ParseInLocation(inventory.FormatoData, *dal, time.Local); err != nil {
+			return fmt.Errorf("data %q non valida, usare il formato DD/MM/YYYY", *dal)
+		}
+	}
+
+	conteggio, err := inventory.LoadConteggio(comando.Arg(0))
+	if err != nil {
+		return err
+	}
+	riconciliazione, err := parser.Inventario.Riconcilia(conteggio, inizio)
+	if err != nil {
+		return err
+	}
+	fmt.Print(formatter.FormatRiconciliazione(riconciliazione))
+
+	if *applica {
+		if err := parser.Inventario.ApplicaConteggio(riconciliazione, *operatore); err != nil {
+			return err
+		}
+		fmt.Printf("Inventario rettificato: %d voci\n", len(riconciliazione.Discrepanze()))
+	}
+	return nil
+}
+
+// esporta i piatti dell'inventario in CSV sul file indicato o, se manca, sullo standard output
+func esporta(args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("indicare al più il file CSV di destinazione")
+	}
+	if len(args) == 0 {
+		return parser.Inventario.EsportaCSV(os.Stdout)
+	}
+
+	file, err := os.Create(args[0])
+	if err != nil {
+		return err
+	}
+	if err := parser.Inventario.EsportaCSV(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// unisce all'inventario i piatti del file CSV, lasciando invariati quelli non citati
+func importa(args []string) error {
+	comando := flag.NewFlagSet("importa", flag.ContinueOnError)
+	operatore := comando.String("operatore", "", "chi esegue l'importazione, obbligatorio")
+	if err := comando.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return err
+	}
+	if comando.NArg() != 1 {
+		return fmt.Errorf("indicare il file CSV da importare")
+	}
+
+	file, err := os.Open(comando.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	importazione, err := parser.Inventario.ImportaCSV(file, *operatore)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Importazione completata: piatti aggiunti %d, aggiornati %d, invariati %d\n",
+		len(importazione.Aggiunti), len(importazione.Aggiornati), len(importazione.Invariati))
+	return nil
+}
+
+// stima quando finiranno i piatti in base al consumo recente e allo storico
+func prevedi(args []string) error {
+	comando := flag.NewFlagSet("prevedi", flag.ContinueOnError)
+	piatto := comando.String("piatto", "", "solo il piatto indicato")
+	fine := comando.String("fine", "", "orario di fine del servizio, HH:MM: indica se le porzioni bastano")
+	finestra := comando.Duration("finestra", inventory.FinestraPredefinita, "periodo recente su cui misurare il consumo (es. 30m)")
+	settimane := comando.Int("settimane", inventory.SettimanePredefinite, "settimane passate da confrontare nello stesso giorno")
+	ordini := comando.String("ordini", "", "file JSON con gli ordini passati non presenti nello storico")
+	if err := comando.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return err
+	}
+
+	opzioni := inventory.OpzioniPrevisione{
+		Adesso:    time.Now(),
+		Finestra:  *finestra,
+		Settimane: *settimane,
+	}
+	if *fine != "" {
+		orario, err := time.Parse(inventory.FormatoOra, *fine)
+		if err != nil {
+			return fmt.Errorf("orario %q non valido, usare il formato HH:MM", *fine)
+		}
+		anno, mese, giorno := opzioni.Adesso.Date()
+		opzioni.FineServizio = time.Date(anno, mese, giorno, orario.Hour(), orario.Minute(), 0, 0, time.Local)
+		if !opzioni.FineServizio.After(opzioni.Adesso) {
+			opzioni.FineServizio = opzioni.FineServizio.AddDate(0, 0, 1)
+		}
+	}
+	if *ordini != "" {
+		passati, err := leggiOrdini(*ordini)
+		if err != nil {
+			return err
+		}
+		opzioni.Ordini = passati
+	}
+
+	var previsioni []inventory.Previsione
+	if *piatto != "" {
+		previsione, err := parser.Inventario.Prevedi(*piatto, opzioni)
+		if err != nil {
+			return err
+		}
+		previsioni = append(previsioni, previsione)
+	} else {
+		var err error
+		if previsioni, err = parser.Inventario.Previsioni(opzioni); err != nil {
+			return err
+		}
+	}
+
+	fmt.Print(formatter.FormatPrevisioni(previsioni, opzioni))
+	return nil
+}
+
+// legge un file JSON con un ordine o un elenco di ordini, nel formato prodotto dalla conversione
+func leggiOrdini(path string) ([]models.Ordine, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("errore nella lettura degli ordini: %w", err)
+	}
+
+	var ordini []models.Ordine
+	if err := json.Unmarshal(data, &ordini); err == nil {
+		return ordini, nil
+	}
+	var ordine models.Ordine
+	if err := json.Unmarshal(data, &ordine); err != nil {
+		return nil, fmt.Errorf("%s: JSON non valido: %w", path, err)
+	}
+	return []models.Ordine{ordine}, nil
+}
+
+// calcola quante porzioni di ogni piatto si possono ancora servire, dopo gli eventuali impegni
+func capacita(args []string) error {
+	comando := flag.NewFlagSet("capacita", flag.ContinueOnError)
+	data := comando.String("data", "", "giorno per cui escludere i lotti scaduti, DD/MM/YYYY (predefinito: oggi)")
+	var impegni elencoFlag
+	comando.Var(&impegni, "impegna", "porzioni da considerare già servite, ripetibile (es. \"pasta al pomodoro +formaggio=10\")")
+	if err := comando.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return err
+	}
+
+	giorno := time.Now()
+	if *data != "" {
+		var err error
+		if giorno, err = time.Parse(inventory.FormatoData, *data); err != nil {
+			return fmt.Errorf("data %q non valida, usare il formato DD/MM/YYYY", *data)
+		}
+	}
+
+	var piano []inventory.Impegno
+	for _, testo := range impegni {
+		impegno, err := parseImpegno(testo)
+		if err != nil {
+			return err
+		}
+		piano = append(piano, impegno)
+	}
+
+	risultato, err := parser.Inventario.Capacita(piano, giorno)
+	if err != nil {
+		return err
+	}
+	fmt.Print(formatter.FormatCapacita(risultato))
+	return nil
+}
+
+// prepara gli ordini ai fornitori per gli ingredienti sotto il livello di riferimento
+func acquisti(args []string) error {
+	comando := flag.NewFlagSet("acquisti", flag.ContinueOnError)
+	formato := comando.String("formato", "testo", "formato di uscita: testo, csv o json")
+	data := comando.String("data", "", "giorno dell'ordine, per escludere i lotti scaduti, DD/MM/YYYY (predefinito: oggi)")
+	if err := comando.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return err
+	}
+
+	giorno := time.Now()
+	if *data != "" {
+		var err error
+		if giorno, err = time.Parse(inventory.FormatoData, *data); err != nil {
+			return fmt.Errorf("data %q non valida, usare il formato DD/MM/YYYY", *data)
+		}
+	}
+
+	ordini := parser.Inventario.OrdiniAcquisto(giorno)
+	switch *formato {
+	case "testo":
+		fmt.Print(formatter.FormatAcquisti(ordini))
+	case "csv":
+		output, err := converter.AcquistiToCSV(ordini)
+		if err != nil {
+			return err
+		}
+		fmt.Print(output)
+	case "json":
+		output, err := converter.AcquistiToJSON(ordini)
+		if err != nil {
+			return err
+		}
+		fmt.Println(output)
+	default:
+		return fmt.Errorf("formato %q non supportato, usare testo, csv o json", *formato)
+	}
+	return nil
+}
+
+// registra la consegna di un ingrediente, con la quantità nell'unità preferita (es. "2 kg")
+func rifornisci(args []string) error {
+	comando := flag.NewFlagSet("rifornisci", flag.ContinueOnError)
+	operatore := comando.String("operatore", "", "chi riceve la consegna, obbligatorio")
+	motivo := comando.String("motivo", "consegna", "motivo del rifornimento")
+	lotto := comando.String("lotto", "", "codice del lotto consegnato")
+	scadenza := comando.String("scadenza", "", "scadenza del lotto, DD/MM/YYYY, obbligatoria con -lotto")
+	if err := comando.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return err
+	}
+	if comando.NArg() != 2 {
+		return fmt.Errorf("indicare l'ingrediente e la quantità (es. pasta \"2 kg\")")
+	}
+	nome := comando.Arg(0)
+
+	quantita, err := parseQuantita(nome, comando.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	if *lotto == "" {
+		if err := parser.Inventario.RifornisciIngredienteIn(nome, quantita, *operatore, *motivo); err != nil {
+			return err
+		}
+	} else {
+		giorno, err := time.Parse(inventory.FormatoData, *scadenza)
+		if err != nil {
+			return fmt.Errorf("scadenza %q non valida, usare il formato DD/MM/YYYY", *scadenza)
+		}
+		convertita, err := parser.Inventario.QuantitaIngrediente(nome, quantita)
+		if err != nil {
+			return err
+		}
+		nuovo := inventory.Lotto{Codice: *lotto, Quantita: convertita, Scadenza: giorno}
+		if err := parser.Inventario.RifornisciLotto(nome, nuovo, *operatore, *motivo); err != nil {
+			return err
+		}
+	}
+
+	ingrediente, _ := parser.Inventario.GetIngrediente(nome)
+	scorta := units.Quantita{Valore: ingrediente.Quantita, Unita: units.Unita(ingrediente.Unita)}
+	fmt.Printf("Rifornimento registrato: %s di %s, scorta attuale %s\n", quantita, nome, scorta.Leggibile())
+	return nil
+}
+
+// legge una quantità con la sua unità; senza unità è nell'unità della scorta dell'ingrediente
+func parseQuantita(nomeIngrediente string, testo string) (units.Quantita, error) {
+	valore, err := strconv.ParseFloat(strings.Replace(strings.TrimSpace(testo), ",", ".", 1), 64)
+	if err != nil {
+		return units.Parse(testo)
+	}
+
+	ingrediente, exists := parser.Inventario.GetIngrediente(nomeIngrediente)
+	if !exists {
+		return units.Quantita{}, fmt.Errorf("l'ingrediente '%s' non è presente in dispensa", nomeIngrediente)
+	}
+	return units.Quantita{Valore: valore, Unita: units.Unita(ingrediente.Unita)}, nil
+}
+
+// analizza un impegno nel formato "piatto [+voce] [-voce]=porzioni"
+func parseImpegno(testo string) (inventory.Impegno, error) {
+	i := strings.LastIndex(testo, "=")
+	if i < 0 {
+		return inventory.Impegno{}, fmt.Errorf("impegno %q non valido, usare il formato \"piatto [+voce] [-voce]=porzioni\"", testo)
+	}
+	porzioni, err := strconv.Atoi(strings.TrimSpace(testo[i+1:]))
+	if err != nil {
+		return inventory.Impegno{}, fmt.Errorf("impegno %q: porzioni non valide", testo)
+	}
+
+	// Le modifiche iniziano dal primo "+" o "-" preceduto da uno spazio
+	piatto := testo[:i]
+	impegno := inventory.Impegno{Porzioni: porzioni}
+	if inizio := modificaImpegnoRegex.FindStringIndex(piatto); inizio != nil {
+		for _, modifica := range modificaImpegnoRegex.FindAllStringSubmatch(piatto[inizio[0]:], -1) {
+			impegno.Modifiche = append(impegno.Modifiche, models.Modifica{Tipo: modifica[1], Voce: strings.TrimSpace(modifica[2])})
+		}
+		piatto = piatto[:inizio[0]]
+	}
+	impegno.Piatto = strings.TrimSpace(piatto)
+
+	return impegno, nil
+}
+
+var modificaImpegnoRegex = regexp.MustCompile(`\s([+-])([^+-]+)`)
+
+// elencoFlag raccoglie i valori di un'opzione ripetibile
+type elencoFlag []string
+
+func (e *elencoFlag) String() string {
+	return strings.Join(*e, ", ")
+}
+
+func (e *elencoFlag) Set(valore string) error {
+	*e = append(*e, valore)
+	return nil
+}
+
+// elenca le versioni del menu oppure confronta le due indicate (la seconda predefinita è il menu corrente)
+func versioni(args []string) error {
+	comando := flag.NewFlagSet("versioni", flag.ContinueOnError)
+	data := comando.String("data", "", "giorno di cui indicare la versione in vigore, DD/MM/YYYY (predefinito: oggi)")
+	if err := comando.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return err
+	}
+
+	switch comando.NArg() {
+	case 0:
+		giorno := time.Now()
+		if *data != "" {
+			var err error
+			if giorno, err = time.Parse(inventory.FormatoData, *data); err != nil {
+				return fmt.Errorf("data %q non valida, usare il formato DD/MM/YYYY", *data)
+			}
+		}
+		inVigore, _ := parser.Inventario.VersioneAl(giorno)
+		fmt.Print(formatter.FormatVersioni(parser.Inventario.Versioni(), inVigore.Nome))
+		return nil
+	case 1, 2:
+		da, err := parser.Inventario.Versione(comando.Arg(0))
+		if err != nil {
+			return err
+		}
+		riferimento := inventory.VersioneCorrente
+		if comando.NArg() == 2 {
+			riferimento = comando.Arg(1)
+		}
+		a, err := parser.Inventario.Versione(riferimento)
+		if err != nil {
+			return err
+		}
+		fmt.Print(formatter.FormatDiffMenu(inventory.ConfrontaVersioni(da, a)))
+		return nil
+	default:
+		return fmt.Errorf("indicare al più le due versioni da confrontare (nome o data di inizio)")
+	}
+}
+
+// valida gli ordini già serviti contro il menu in vigore alla loro data, senza toccare l'inventario
+func verifica(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("indicare almeno un file di ordine")
+	}
+
+	for _, file := range args {
+		lines, err := readInputFile(file)
+		if err != nil {
+			return err
+		}
+		ordine, err := parser.VerificaOrdine(lines)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+
+		menu := "il menu corrente"
+		if versione, exists, _ := parser.Inventario.VersioneOrdine(ordine); exists {
+			menu = fmt.Sprintf("la versione '%s' del menu", versione.Nome)
+		}
+		fmt.Printf("%s: ordine del %s valido secondo %s\n", file, ordine.Data, menu)
+	}
+	return nil
+}
+
+// stampa il menu corrente secondo le opzioni del comando "menu"
+func stampaMenu(args []string) error {
+	comando := flag.NewFlagSet("menu", flag.ContinueOnError)
+	formato := comando.String("formato", "testo", "formato di uscita: testo, json o markdown")
+	portata := comando.String("portata", "", "mostra solo i piatti della portata indicata (PRIMO, SECONDO, CONTORNO)")
+	stazione := comando.String("stazione", "", "mostra solo i piatti preparati dalla stazione indicata")
+	disponibili := comando.Bool("disponibili", false, "mostra solo i piatti ordinabili")
+	senza := comando.String("senza", "", "esclude i piatti con questi allergeni, separati da virgola")
+	tag := comando.String("tag", "", "mostra solo i piatti con tutti questi tag, separati da virgola (es. vegano)")
+	suRichiesta := comando.Bool("su-richiesta", false, "con -tag, include i piatti che ottengono i tag rimuovendo qualche voce")
+	ordina := comando.String("ordina", inventory.OrdinaPerPortata, "ordinamento: portata, nome, prezzo o rimanenti")
+	data := comando.String("data", "", "giorno per cui verificare la disponibilità, DD/MM/YYYY (predefinito: oggi)")
+	ora := comando.String("ora", "", "orario per cui verificare la disponibilità, HH:MM (predefinito: adesso)")
+	if err := comando.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return err
+	}
+
+	adesso := time.Now()
+	giorno := adesso
+	if *data != "" {
+		var err error
+		if giorno, err = time.Parse(inventory.FormatoData, *data); err != nil {
+			return fmt.Errorf("data %q non valida, usare il formato DD/MM/YYYY", *data)
+		}
+	}
+	if *ora == "" && *data == "" {
+		*ora = adesso.Format(inventory.FormatoOra)
+	}
+	if *ora != "" {
+		if _, err := time.Parse(inventory.FormatoOra, *ora); err != nil {
+			return fmt.Errorf("orario %q non valido, usare il formato HH:MM", *ora)
+		}
+	}
+
+	voci, err := parser.Inventario.Menu(inventory.FiltroMenu{
+		Portata:          strings.ToUpper(*portata),
+		Stazione:         *stazione,
+		SoloDisponibili:  *disponibili,
+		Giorno:           giorno,
+		Ora:              *ora,
+		SenzaAllergeni:   dividiElenco(*senza),
+		Tag:              dividiElenco(*tag),
+		AncheSuRichiesta: *suRichiesta,
+		Ordinamento:      *ordina,
+	})
+	if err != nil {
+		return err
+	}
+
+	switch *formato {
+	case "testo":
+		fmt.Print(formatter.FormatMenu(voci))
+	case "markdown":
+		fmt.Print(formatter.FormatMenuMarkdown(voci))
+	case "json":
+		output, err := converter.MenuToJSON(voci)
+		if err != nil {
+			return err
+		}
+		fmt.Println(output)
+	default:
+		return fmt.Errorf("formato %q non supportato, usare testo, json o markdown", *formato)
+	}
+
+	return nil
+}
+
+// divide un elenco separato da virgole, ignorando le voci vuote
+func dividiElenco(elenco string) []string {
+	var voci []string
+	for _, voce := range strings.Split(elenco, ",") {
+		if voce = strings.TrimSpace(voce); voce != "" {
+			voci = append(voci, voce)
+		}
+	}
+	return voci
+}
+
+// apre il log degli eventi dell'inventario sul file indicato, o su stderr
+func apriLogEventi(filename string) (*log.Logger, error) {
+	if filename == "" {
+		return log.New(os.Stderr, "", log.LstdFlags), nil
+	}
+
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("errore nell'apertura del file: %w", err)
+	}
+
+	return log.New(file, "", log.LstdFlags), nil
+}
+
 // Gstisce gli errori in modo specifico in base al tipo
 func handleError(err error) {
 	// Controlla se è un errore personalizzato
@@ -91,6 +801,18 @@ func handleError(err error) {
 			fmt.Println("Suggerimento: Controllare il menu per piatti alternativi disponibili.")
 		case errors.ErrCodeModificaNonValida:
 			fmt.Println("Suggerimento: Consultare il personale per le modifiche consentite.")
+		case errors.ErrCodeVarianteNonValida:
+			fmt.Println("Suggerimento: Consultare il menu per le varianti disponibili (es. mezza porzione).")
+		case errors.ErrCodeOpzioneMancante, errors.ErrCodeOpzioneNonValida:
+			fmt.Println("Suggerimento: Chiedere al cliente come desidera il piatto (es. cottura).")
+		case errors.ErrCodePortataErrata:
+			fmt.Println("Suggerimento: Spostare il piatto nella riga della portata corretta.")
+		case errors.ErrCodeFuoriOrario:
+			fmt.Println("Suggerimento: Proporre un piatto disponibile in questo giorno e orario.")
+		case errors.ErrCodePostoNonValido:
+			fmt.Println("Suggerimento: Verificare i coperti dichiarati e i posti indicati con @.")
+		case errors.ErrCodeDatiCliente:
+			fmt.Println("Suggerimento: Per asporto e domicilio indicare sempre nome e telefono del cliente.")
 		case errors.ErrCodeSintassiGenerale:
 			fmt.Println("Suggerimento: Verificare la sintassi del file di ordine.")
 		}
@@ -5,9 +5,27 @@ type Modifica struct {
 	Voce string
 }
 
+type Opzione struct {
+	Nome   string // es. "cottura"
+	Valore string // es. "al sangue"
+}
+
 type Piatto struct {
 	Nome      string
+	Variante  string `json:",omitempty" xml:",omitempty" yaml:",omitempty"` // es. "mezza", vuota per la porzione normale
+	Posto     int    `json:",omitempty" xml:",omitempty" yaml:",omitempty"` // posto al tavolo dell'ospite, 0 = non indicato
 	Modifiche []Modifica
+	Opzioni   []Opzione
+	Tag       []string       `json:",omitempty" xml:",omitempty" yaml:",omitempty"` // indicazioni alimentari dopo le modifiche
+	Lotti     []TracciaLotto `json:",omitempty" xml:",omitempty" yaml:",omitempty"` // lotti degli ingredienti usati, per la tracciabilità
+}
+
+// TracciaLotto indica quanto di un lotto di ingrediente è finito in un piatto servito
+type TracciaLotto struct {
+	Ingrediente string
+	Lotto       string
+	Scadenza    string // DD/MM/YYYY
+	Quantita    float64
 }
 
 type Comanda struct {
@@ -17,8 +35,46 @@ type Comanda struct {
 	Contorno *Piatto
 }
 
+// Tipi di ordine
+const (
+	TipoSala      = "SALA"
+	TipoAsporto   = "ASPORTO"
+	TipoDomicilio = "DOMICILIO"
+)
+
+type Cliente struct {
+	Nome      string
+	Telefono  string
+	Indirizzo string `json:",omitempty" xml:",omitempty" yaml:",omitempty"` // solo per la consegna a domicilio
+}
+
 type Ordine struct {
-	Tavolo  int
+	Tipo    string
+	Tavolo  int `json:",omitempty" xml:",omitempty" yaml:",omitempty"` // solo per gli ordini in sala
+	Coperti int `json:",omitempty" xml:",omitempty" yaml:",omitempty"` // persone al tavolo
 	Data    string
+	Ora     string   `json:",omitempty" xml:",omitempty" yaml:",omitempty"` // orario dell'ordine in sala, di ritiro o di consegna
+	Cliente *Cliente `json:",omitempty" xml:",omitempty" yaml:",omitempty"`
 	Comande []Comanda
 }
+
+// TipoOrdine restituisce il tipo dell'ordine; quelli senza tipo, come gli ordini
+// precedenti all'asporto e al domicilio, sono ordini in sala
+func (o Ordine) TipoOrdine() string {
+	if o.Tipo == "" {
+		return TipoSala
+	}
+	return o.Tipo
+}
+
+// HaPosti indica se almeno un piatto dell'ordine ha il posto al tavolo
+func (o Ordine) HaPosti() bool {
+	for _, comanda := range o.Comande {
+		for _, piatto := range []*Piatto{comanda.Primo, comanda.Secondo, comanda.Contorno} {
+			if piatto != nil && piatto.Posto > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,136 @@
+package money
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Regexp per validare un importo in euro (es. 8, 8.5, 8.50, 8,50)
+var importoRegex = regexp.MustCompile(`^(-)?(\d+)(?:[.,](\d{1,2}))?$`)
+
+// Importo è una somma in euro espressa in centesimi, per evitare gli errori di arrotondamento dei float
+type Importo int64
+
+// Euro costruisce un importo a partire da euro e centesimi
+func Euro(euro int64, centesimi int64) Importo {
+	return Importo(euro*100 + centesimi)
+}
+
+// Parse converte un importo testuale in euro (es. "8.50" o "8,50")
+func Parse(testo string) (Importo, error) {
+	match := importoRegex.FindStringSubmatch(strings.TrimSpace(testo))
+	if match == nil {
+		return 0, fmt.Errorf("importo %q non valido, usare il formato 8.50", testo)
+	}
+
+	euro, err := strconv.ParseInt(match[2], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("importo %q fuori scala", testo)
+	}
+
+	var centesimi int64
+	if decimali := match[3]; decimali != "" {
+		if len(decimali) == 1 {
+			decimali += "0"
+		}
+		centesimi, _ = strconv.ParseInt(decimali, 10, 64)
+	}
+
+	importo := Euro(euro, centesimi)
+	if match[1] == "-" {
+		importo = -importo
+	}
+	return importo, nil
+}
+
+// Centesimi restituisce l'importo in centesimi
+func (i Importo) Centesimi() int64 {
+	return int64(i)
+}
+
+// Moltiplica restituisce l'importo moltiplicato per una quantità intera
+func (i Importo) Moltiplica(quantita int) Importo {
+	return i * Importo(quantita)
+}
+
+// Dividi ripartisce l'importo in parti che differiscono al massimo di un centesimo
+// e la cui somma è esattamente l'importo di partenza. I centesimi in più vanno alle prime parti
+func (i Importo) Dividi(parti int) []Importo {
+	if parti <= 0 {
+		return nil
+	}
+
+	quote := make([]Importo, parti)
+	base := int64(i) / int64(parti)
+	resto := int64(i) % int64(parti)
+
+	for p := range quote {
+		quote[p] = Importo(base)
+		switch {
+		case resto > 0 && int64(p) < resto:
+			quote[p]++
+		case resto < 0 && int64(p) < -resto:
+			quote[p]--
+		}
+	}
+
+	return quote
+}
+
+// Percentuale restituisce la percentuale indicata dell'importo, arrotondata al centesimo
+func (i Importo) Percentuale(percentuale int) Importo {
+	return Importo(dividiArrotondando(int64(i)*int64(percentuale), 100))
+}
+
+// ScorporaIVA separa imponibile e IVA da un importo IVA inclusa
+func (i Importo) ScorporaIVA(aliquota int) (imponibile Importo, iva Importo) {
+	imponibile = Importo(dividiArrotondando(int64(i)*100, int64(100+aliquota)))
+	return imponibile, i - imponibile
+}
+
+// String formatta l'importo all'italiana, es. "8,50 €"
+func (i Importo) String() string {
+	return strings.Replace(i.decimale(), ".", ",", 1) + " €"
+}
+
+// MarshalText rappresenta l'importo come numero decimale, es. "8.50", in JSON, XML e YAML
+func (i Importo) MarshalText() ([]byte, error) {
+	return []byte(i.decimale()), nil
+}
+
+// UnmarshalText legge un importo decimale, es. "8.50"
+func (i *Importo) UnmarshalText(data []byte) error {
+	importo, err := Parse(string(data))
+	if err != nil {
+		return err
+	}
+	*i = importo
+	return nil
+}
+
+func (i Importo) decimale() string {
+	segno := ""
+	c := int64(i)
+	if c < 0 {
+		segno = "-"
+		c = -c
+	}
+	return fmt.Sprintf("%s%d.%02d", segno, c/100, c%100)
+}
+
+// dividiArrotondando divide arrotondando al più vicino, con le metà lontano dallo zero
+func dividiArrotondando(a int64, b int64) int64 {
+	if (a < 0) != (b < 0) {
+		return -((abs(a) + abs(b)/2) / abs(b))
+	}
+	return (abs(a) + abs(b)/2) / abs(b)
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -3,18 +3,27 @@ package parser
 import (
 	"fmt"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/branila/restaurant-protocol/errors"
 	"github.com/branila/restaurant-protocol/inventory"
 	"github.com/branila/restaurant-protocol/models"
+	"github.com/branila/restaurant-protocol/validation"
 )
 
 var (
 	// Regexp per validare il formato della data
 	dateRegex = regexp.MustCompile(`^\d{2}/\d{2}/\d{4}$`)
 
+	// Regexp per il nome del piatto, le modifiche e le opzioni
+	nomePiattoRegex = regexp.MustCompile(`"([^"]+)"(?:\[([^\]]*)\])?`)
+	modificheRegex  = regexp.MustCompile(`([+-])"([^"]+)"`)
+	opzioniRegex    = regexp.MustCompile(`([A-Za-z_]+)="([^"]*)"`)
+	postoRegex      = regexp.MustCompile(`^@(\S*)\s+`)
+
 	// Inventario globale
 	Inventario *inventory.Inventory
 )
@@ -24,7 +33,19 @@ func Init() {
 	Inventario = inventory.DefaultInventory()
 }
 
+// ParseOrdine analizza un ordine, lo valida contro il menu in vigore alla sua data e ne scala
+// i piatti dall'inventario
 func ParseOrdine(lines []string) (models.Ordine, error) {
+	return analizzaOrdine(lines, true)
+}
+
+// VerificaOrdine analizza un ordine già servito e lo valida contro il menu in vigore alla sua data,
+// senza controllare né scalare porzioni e scorte
+func VerificaOrdine(lines []string) (models.Ordine, error) {
+	return analizzaOrdine(lines, false)
+}
+
+func analizzaOrdine(lines []string, scala bool) (models.Ordine, error) {
 	// Inizializza l'inventario se non è già stato fatto
 	if Inventario == nil {
 		Init()
@@ -42,6 +63,18 @@ func ParseOrdine(lines []string) (models.Ordine, error) {
 		return ordine, err
 	}
 
+	// Portate, modifiche e opzioni si verificano sul menu in vigore alla data dell'ordine,
+	// le porzioni e le scorte sull'inventario attuale
+	giorno, err := time.Parse(inventory.FormatoData, ordine.Data)
+	if err != nil {
+		return ordine, errors.NewFormatoDataError(ordine.Data)
+	}
+	menu := Inventario.MenuAl(giorno)
+	scorte := Inventario
+	if !scala {
+		scorte = nil
+	}
+
 	// Analizza il resto delle righe
 	for i := 1; i < len(lines); i++ {
 		line := strings.TrimSpace(lines[i])
@@ -63,11 +96,16 @@ func ParseOrdine(lines []string) (models.Ordine, error) {
 			if err != nil {
 				return ordine, err
 			}
+			for _, precedente := range ordine.Comande {
+				if precedente.Numero == comanda.Numero {
+					return ordine, errors.NewComandaDuplicataError(strconv.Itoa(comanda.Numero))
+				}
+			}
 			ordine.Comande = append(ordine.Comande, *comanda)
 			comandaCorrenteIndex = len(ordine.Comande) - 1
 		} else if comandaCorrenteIndex >= 0 {
 			// Analizza il piatto all'interno della comanda corrente
-			if err := parsePiatto(line, &ordine.Comande[comandaCorrenteIndex]); err != nil {
+			if err := parsePiatto(line, &ordine, &ordine.Comande[comandaCorrenteIndex], menu, scorte); err != nil {
 				return ordine, err
 			}
 		} else {
@@ -87,33 +125,215 @@ func ParseOrdine(lines []string) (models.Ordine, error) {
 		return ordine, errors.NewOrdineVuotoError()
 	}
 
+	if !scala {
+		return ordine, nil
+	}
+
+	// Scala tutti i piatti dell'ordine in un colpo solo: se uno non è disponibile non ne viene scalato nessuno.
+	// I lotti scaduti nel giorno dell'ordine non vengono usati
+	piatti := piattiOrdine(ordine)
+	prenotazione, err := Inventario.RiservaAl(richiesteOrdine(ordine), giorno)
+	if err != nil {
+		return ordine, err
+	}
+	for i, traccia := range prenotazione.Tracciabilita() {
+		piatti[i].Lotti = traccia
+	}
+	if err := prenotazione.Conferma(); err != nil {
+		return ordine, err
+	}
+
 	return ordine, nil
 }
 
+// Elenca i piatti dell'ordine, comanda per comanda
+func piattiOrdine(ordine models.Ordine) []*models.Piatto {
+	var piatti []*models.Piatto
+
+	for _, comanda := range ordine.Comande {
+		for _, piatto := range []*models.Piatto{comanda.Primo, comanda.Secondo, comanda.Contorno} {
+			if piatto != nil {
+				piatti = append(piatti, piatto)
+			}
+		}
+	}
+
+	return piatti
+}
+
+// Elenca i piatti da scalare dall'inventario
+func richiesteOrdine(ordine models.Ordine) []inventory.Richiesta {
+	riferimento := riferimentoOrdine(ordine)
+	var richieste []inventory.Richiesta
+
+	for _, comanda := range ordine.Comande {
+		for _, piatto := range []*models.Piatto{comanda.Primo, comanda.Secondo, comanda.Contorno} {
+			if piatto != nil {
+				richieste = append(richieste, inventory.Richiesta{
+					Piatto:    piatto.Nome,
+					Variante:  piatto.Variante,
+					Modifiche: piatto.Modifiche,
+					Ordine:    riferimento,
+					Comanda:   comanda.Numero,
+				})
+			}
+		}
+	}
+
+	return richieste
+}
+
+// Descrive l'ordine per lo storico dell'inventario (es. "tavolo 1 del 13/11/2025 13:00")
+func riferimentoOrdine(ordine models.Ordine) string {
+	var riferimento string
+	switch ordine.Tipo {
+	case models.TipoAsporto, models.TipoDomicilio:
+		riferimento = strings.ToLower(ordine.Tipo)
+		if ordine.Cliente != nil {
+			riferimento = fmt.Sprintf("%s %s", riferimento, ordine.Cliente.Nome)
+		}
+	default:
+		riferimento = fmt.Sprintf("tavolo %d", ordine.Tavolo)
+	}
+
+	riferimento = fmt.Sprintf("%s del %s", riferimento, ordine.Data)
+	if ordine.Ora != "" {
+		riferimento = fmt.Sprintf("%s %s", riferimento, ordine.Ora)
+	}
+	return riferimento
+}
+
 // Analizza la riga di intestazione dell'ordine
+//
+// Formati supportati:
+//
+//	ORDINE [tavolo] [data] [COPERTI n] [ORA HH:MM]
+//	ORDINE ASPORTO [data] CLIENTE "[nome]" TELEFONO "[numero]" RITIRO [HH:MM]
+//	ORDINE DOMICILIO [data] CLIENTE "[nome]" TELEFONO "[numero]" INDIRIZZO "[indirizzo]" [CONSEGNA HH:MM]
 func parseIntestazione(line string, ordine *models.Ordine) error {
-	parts := strings.Split(line, " ")
+	parts, err := tokenizza(line)
+	if err != nil {
+		return err
+	}
 	if len(parts) < 3 || parts[0] != "ORDINE" {
-		return errors.NewSyntaxError(line, "L'intestazione deve essere nel formato 'ORDINE [numero] [data]'")
+		return errors.NewSyntaxError(line, "L'intestazione deve essere nel formato 'ORDINE [numero] [data]' oppure 'ORDINE ASPORTO|DOMICILIO [data] ...'")
 	}
 
-	// Analizza il numero del tavolo
-	tavolo, err := strconv.Atoi(parts[1])
-	if err != nil {
-		return errors.NewNumeroNonValidoError("tavolo", parts[1])
+	// Analizza il tipo di ordine o il numero del tavolo
+	switch parts[1] {
+	case models.TipoAsporto, models.TipoDomicilio:
+		ordine.Tipo = parts[1]
+	default:
+		tavolo, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return errors.NewNumeroNonValidoError("tavolo", parts[1])
+		}
+		ordine.Tipo = models.TipoSala
+		ordine.Tavolo = tavolo
 	}
-	ordine.Tavolo = tavolo
 
 	// Analizza la data
 	data := parts[2]
 	if !dateRegex.MatchString(data) {
 		return errors.NewFormatoDataError(data)
 	}
+	if _, err := time.Parse(inventory.FormatoData, data); err != nil {
+		return errors.NewFormatoDataError(data)
+	}
 	ordine.Data = data
 
+	// Analizza i campi aggiuntivi nel formato CHIAVE valore
+	if err := parseCampiIntestazione(line, parts[3:], ordine); err != nil {
+		return err
+	}
+
+	return validation.ValidateIntestazione(*ordine)
+}
+
+// Analizza le coppie CHIAVE valore che seguono la data nell'intestazione
+func parseCampiIntestazione(line string, campi []string, ordine *models.Ordine) error {
+	if len(campi)%2 != 0 {
+		return errors.NewSyntaxError(line, fmt.Sprintf("Manca il valore per il campo %s", campi[len(campi)-1]))
+	}
+
+	cliente := func() *models.Cliente {
+		if ordine.Cliente == nil {
+			ordine.Cliente = &models.Cliente{}
+		}
+		return ordine.Cliente
+	}
+
+	for i := 0; i < len(campi); i += 2 {
+		chiave, valore := campi[i], campi[i+1]
+
+		switch chiave {
+		case "CLIENTE":
+			cliente().Nome = valore
+		case "TELEFONO":
+			cliente().Telefono = valore
+		case "INDIRIZZO":
+			cliente().Indirizzo = valore
+		case "COPERTI":
+			coperti, err := strconv.Atoi(valore)
+			if err != nil || coperti <= 0 {
+				return errors.NewNumeroNonValidoError("coperti", valore)
+			}
+			if ordine.Tipo != models.TipoSala {
+				return errors.NewSyntaxError(line, fmt.Sprintf("Il campo %s non è previsto per un ordine di tipo %s", chiave, ordine.Tipo))
+			}
+			ordine.Coperti = coperti
+		case "ORA", "RITIRO", "CONSEGNA":
+			previsto := map[string]string{
+				models.TipoSala:      "ORA",
+				models.TipoAsporto:   "RITIRO",
+				models.TipoDomicilio: "CONSEGNA",
+			}[ordine.Tipo]
+			if chiave != previsto {
+				return errors.NewSyntaxError(line, fmt.Sprintf("Il campo %s non è previsto per un ordine di tipo %s", chiave, ordine.Tipo))
+			}
+			ordine.Ora = valore
+		default:
+			return errors.NewSyntaxError(line, fmt.Sprintf("Campo dell'intestazione non riconosciuto: %s", chiave))
+		}
+	}
+
 	return nil
 }
 
+// Divide una riga in parole, considerando il testo tra virgolette come una parola sola
+func tokenizza(line string) ([]string, error) {
+	var tokens []string
+	var corrente strings.Builder
+	traVirgolette := false
+	inToken := false
+
+	for _, r := range line {
+		switch {
+		case r == '"':
+			traVirgolette = !traVirgolette
+			inToken = true
+		case r == ' ' && !traVirgolette:
+			if inToken {
+				tokens = append(tokens, corrente.String())
+				corrente.Reset()
+				inToken = false
+			}
+		default:
+			corrente.WriteRune(r)
+			inToken = true
+		}
+	}
+
+	if traVirgolette {
+		return nil, errors.NewSyntaxError(line, "Virgolette non chiuse")
+	}
+	if inToken {
+		tokens = append(tokens, corrente.String())
+	}
+
+	return tokens, nil
+}
+
 // Analizza una riga di comanda
 func parseComanda(line string) (*models.Comanda, error) {
 	parts := strings.Split(line, " ")
@@ -130,8 +350,9 @@ func parseComanda(line string) (*models.Comanda, error) {
 	return &models.Comanda{Numero: numero}, nil
 }
 
-// Analizza una riga di piatto
-func parsePiatto(line string, comanda *models.Comanda) error {
+// Analizza una riga di piatto. Il menu verifica la composizione del piatto, le scorte
+// la sua disponibilità e sono nil per gli ordini già serviti
+func parsePiatto(line string, ordine *models.Ordine, comanda *models.Comanda, menu, scorte *inventory.Inventory) error {
 	// Separa il tipo di piatto dal resto della riga
 	parts := strings.SplitN(line, " ", 2)
 	if len(parts) < 2 {
@@ -141,28 +362,61 @@ func parsePiatto(line string, comanda *models.Comanda) error {
 	tipoPiatto := parts[0]
 	restoDellaPiatto := parts[1]
 
+	// Analizza il posto al tavolo, se indicato (es. PRIMO @2 "...")
+	posto := 0
+	if postoMatch := postoRegex.FindStringSubmatch(restoDellaPiatto); postoMatch != nil {
+		numero, err := strconv.Atoi(postoMatch[1])
+		if err != nil {
+			return errors.NewNumeroNonValidoError("posto", postoMatch[1])
+		}
+		if err := validation.ValidatePosto(numero, ordine.Coperti); err != nil {
+			return err
+		}
+		posto = numero
+		restoDellaPiatto = restoDellaPiatto[len(postoMatch[0]):]
+	}
+
 	// Estrai il nome del piatto tra virgolette
-	regexPiatto := regexp.MustCompile(`"([^"]+)"`)
-	nomeMatch := regexPiatto.FindStringSubmatch(restoDellaPiatto)
+	nomeMatch := nomePiattoRegex.FindStringSubmatch(restoDellaPiatto)
 	if len(nomeMatch) < 2 {
 		return errors.NewSyntaxError(line, "Il nome del piatto deve essere tra virgolette")
 	}
 
 	nomePiatto := nomeMatch[1]
 
-	// Verifica la disponibilità del piatto
-	if err := Inventario.VerificaDisponibilita(nomePiatto); err != nil {
+	// Analizza la variante, se indicata (es. "pasta al pomodoro"[mezza])
+	variante := nomeMatch[2]
+	if variante == "" && strings.HasSuffix(nomeMatch[0], "]") {
+		return errors.NewSyntaxError(line, "Indicare il nome della variante tra le parentesi quadre")
+	}
+	if err := menu.VerificaVariante(nomePiatto, variante); err != nil {
+		return err
+	}
+
+	// Il calendario è quello del menu in vigore alla data dell'ordine; porzioni e scorte si
+	// verificano sull'inventario attuale, tranne per gli ordini già serviti
+	giorno, err := time.Parse(inventory.FormatoData, ordine.Data)
+	if err != nil {
+		return errors.NewFormatoDataError(ordine.Data)
+	}
+	if err := menu.VerificaCalendario(nomePiatto, giorno, ordine.Ora); err != nil {
 		return err
 	}
+	if scorte != nil {
+		if err := scorte.VerificaScorteAl(nomePiatto, giorno); err != nil {
+			return err
+		}
+	}
 
 	// Crea il piatto con le modifiche
 	piatto := &models.Piatto{
 		Nome:      nomePiatto,
+		Variante:  variante,
+		Posto:     posto,
 		Modifiche: []models.Modifica{},
 	}
 
 	// Analizza le modifiche (+ e -)
-	modificheRegex := regexp.MustCompile(`([+-])"([^"]+)"`)
 	modificheMatch := modificheRegex.FindAllStringSubmatch(restoDellaPiatto, -1)
 
 	for _, mod := range modificheMatch {
@@ -174,7 +428,7 @@ func parsePiatto(line string, comanda *models.Comanda) error {
 		voceModifica := mod[2]
 
 		// Verifica che la modifica sia consentita
-		if err := Inventario.VerificaModifica(nomePiatto, tipoModifica, voceModifica); err != nil {
+		if err := menu.VerificaModifica(nomePiatto, tipoModifica, voceModifica); err != nil {
 			return err
 		}
 
@@ -185,6 +439,20 @@ func parsePiatto(line string, comanda *models.Comanda) error {
 		})
 	}
 
+	// Verifica che il piatto appartenga alla portata in cui è stato ordinato, prima delle
+	// opzioni: un piatto nella portata sbagliata va segnalato come tale anche se manca un'opzione
+	if err := menu.VerificaPortata(nomePiatto, tipoPiatto); err != nil {
+		return err
+	}
+
+	// Analizza le opzioni (nome="valore")
+	if err := parseOpzioni(restoDellaPiatto, piatto, menu); err != nil {
+		return err
+	}
+
+	// Ricalcola le indicazioni alimentari tenendo conto delle modifiche
+	piatto.Tag = menu.TagAlimentari(nomePiatto, piatto.Modifiche)
+
 	// Assegna il piatto alla comanda in base al tipo
 	switch tipoPiatto {
 	case "PRIMO":
@@ -206,12 +474,34 @@ func parsePiatto(line string, comanda *models.Comanda) error {
 		return errors.NewSyntaxError(line, fmt.Sprintf("Tipo di piatto non riconosciuto: %s", tipoPiatto))
 	}
 
-	// Decrementa la disponibilità del piatto nell'inventario
-	if err := Inventario.DecrementaDisponibilita(nomePiatto); err != nil {
-		return err
+	return nil
+}
+
+// Analizza le opzioni di un piatto e verifica quelle obbligatorie
+func parseOpzioni(restoDellaPiatto string, piatto *models.Piatto, menu *inventory.Inventory) error {
+	opzioniMatch := opzioniRegex.FindAllStringSubmatch(restoDellaPiatto, -1)
+	presenti := make([]string, 0, len(opzioniMatch))
+
+	for _, opz := range opzioniMatch {
+		nomeOpzione := opz[1]
+		valore := opz[2]
+
+		if slices.Contains(presenti, nomeOpzione) {
+			return errors.NewOpzioneNonValidaError(piatto.Nome, nomeOpzione, "L'opzione è stata indicata più di una volta")
+		}
+
+		if err := menu.VerificaOpzione(piatto.Nome, nomeOpzione, valore); err != nil {
+			return err
+		}
+
+		piatto.Opzioni = append(piatto.Opzioni, models.Opzione{
+			Nome:   nomeOpzione,
+			Valore: valore,
+		})
+		presenti = append(presenti, nomeOpzione)
 	}
 
-	return nil
+	return menu.VerificaOpzioniObbligatorie(piatto.Nome, presenti)
 }
 
 // validaComanda verifica che una comanda sia valida
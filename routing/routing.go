@@ -0,0 +1,73 @@
+package routing
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/branila/restaurant-protocol/inventory"
+	"github.com/branila/restaurant-protocol/models"
+)
+
+// Ticket è la parte di un ordine che spetta a una stazione della cucina
+type Ticket struct {
+	Stazione  string
+	Stampante string        // destinazione del ticket, vuota se la stazione non stampa
+	Ordine    models.Ordine // l'ordine con i soli piatti della stazione
+}
+
+// Instrada divide l'ordine in un ticket per ogni stazione che deve preparare almeno un piatto.
+// Le comande mantengono il loro numero e restano solo quelle con piatti per la stazione.
+// La disponibilità non viene verificata di nuovo: l'ordine è già stato riservato per intero
+// da parser.ParseOrdine, così un ordine viene accettato o rifiutato da tutte le stazioni insieme
+func Instrada(ordine models.Ordine, inv *inventory.Inventory) ([]Ticket, error) {
+	comande := make(map[string][]models.Comanda)
+
+	for _, comanda := range ordine.Comande {
+		perStazione := make(map[string]*models.Comanda)
+
+		for i, piatto := range []*models.Piatto{comanda.Primo, comanda.Secondo, comanda.Contorno} {
+			if piatto == nil {
+				continue
+			}
+
+			stazione, err := inv.StazionePiatto(piatto.Nome)
+			if err != nil {
+				return nil, fmt.Errorf("comanda %d: %w", comanda.Numero, err)
+			}
+			parziale := perStazione[stazione]
+			if parziale == nil {
+				parziale = &models.Comanda{Numero: comanda.Numero}
+				perStazione[stazione] = parziale
+			}
+
+			switch i {
+			case 0:
+				parziale.Primo = piatto
+			case 1:
+				parziale.Secondo = piatto
+			case 2:
+				parziale.Contorno = piatto
+			}
+		}
+
+		for stazione, parziale := range perStazione {
+			comande[stazione] = append(comande[stazione], *parziale)
+		}
+	}
+
+	tickets := make([]Ticket, 0, len(comande))
+	for stazione, comandeStazione := range comande {
+		dettagli, _ := inv.GetStazione(stazione)
+
+		parziale := ordine
+		parziale.Comande = comandeStazione
+		tickets = append(tickets, Ticket{
+			Stazione:  stazione,
+			Stampante: dettagli.Stampante,
+			Ordine:    parziale,
+		})
+	}
+	sort.Slice(tickets, func(i, j int) bool { return tickets[i].Stazione < tickets[j].Stazione })
+
+	return tickets, nil
+}
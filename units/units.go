@@ -0,0 +1,177 @@
+package units
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Unita è un'unità di misura delle scorte
+type Unita string
+
+// Unità ammesse. Le scorte sono sempre tenute nell'unità base della loro grandezza: g, ml o pz
+const (
+	Grammi      Unita = "g"
+	Etti        Unita = "hg"
+	Chilogrammi Unita = "kg"
+	Millilitri  Unita = "ml"
+	Centilitri  Unita = "cl"
+	Litri       Unita = "l"
+	Pezzi       Unita = "pz"
+)
+
+// Grandezza è ciò che un'unità misura: due unità sono convertibili solo se misurano la stessa grandezza
+type Grandezza string
+
+const (
+	Massa     Grandezza = "massa"
+	Volume    Grandezza = "volume"
+	Conteggio Grandezza = "conteggio"
+)
+
+// ErrIncompatibili segnala un'operazione tra unità di grandezze diverse, es. litri e grammi
+var ErrIncompatibili = errors.New("unità incompatibili")
+
+type definizione struct {
+	grandezza Grandezza
+	base      Unita
+	fattore   float64 // quante unità base vale un'unità
+}
+
+var unita = map[Unita]definizione{
+	Grammi:      {Massa, Grammi, 1},
+	Etti:        {Massa, Grammi, 100},
+	Chilogrammi: {Massa, Grammi, 1000},
+	Millilitri:  {Volume, Millilitri, 1},
+	Centilitri:  {Volume, Millilitri, 10},
+	Litri:       {Volume, Millilitri, 1000},
+	Pezzi:       {Conteggio, Pezzi, 1},
+}
+
+// Regexp per una quantità con la sua unità (es. 200 g, 1.5kg, 0,75 l)
+var quantitaRegex = regexp.MustCompile(`^(-?\d+(?:[.,]\d+)?)\s*([[:alpha:]]+)$`)
+
+// Quantita è un valore con la sua unità di misura
+type Quantita struct {
+	Valore float64
+	Unita  Unita
+}
+
+// ParseUnita riconosce un'unità di misura, senza distinguere maiuscole e minuscole
+func ParseUnita(testo string) (Unita, error) {
+	u := Unita(strings.ToLower(strings.TrimSpace(testo)))
+	if _, exists := unita[u]; !exists {
+		return "", fmt.Errorf("unità %q non valida, usare g, hg, kg, ml, cl, l o pz", testo)
+	}
+	return u, nil
+}
+
+// Parse converte una quantità testuale con l'unità (es. "1.5 kg" o "200g")
+func Parse(testo string) (Quantita, error) {
+	match := quantitaRegex.FindStringSubmatch(strings.TrimSpace(testo))
+	if match == nil {
+		return Quantita{}, fmt.Errorf("quantità %q non valida, usare il formato 200 g o 1.5 kg", testo)
+	}
+
+	valore, err := strconv.ParseFloat(strings.Replace(match[1], ",", ".", 1), 64)
+	if err != nil {
+		return Quantita{}, fmt.Errorf("quantità %q non valida", testo)
+	}
+	u, err := ParseUnita(match[2])
+	if err != nil {
+		return Quantita{}, err
+	}
+	return Quantita{Valore: valore, Unita: u}, nil
+}
+
+// Grandezza restituisce ciò che l'unità misura
+func (u Unita) Grandezza() Grandezza {
+	return unita[u].grandezza
+}
+
+// Base restituisce l'unità base della grandezza misurata da u
+func (u Unita) Base() Unita {
+	return unita[u].base
+}
+
+// Valida indica se l'unità è tra quelle ammesse
+func (u Unita) Valida() bool {
+	_, exists := unita[u]
+	return exists
+}
+
+// Compatibili indica se due unità misurano la stessa grandezza
+func Compatibili(a, b Unita) bool {
+	return a.Valida() && b.Valida() && a.Grandezza() == b.Grandezza()
+}
+
+// In converte la quantità nell'unità indicata
+func (q Quantita) In(u Unita) (Quantita, error) {
+	if !q.Unita.Valida() {
+		return Quantita{}, fmt.Errorf("unità %q non valida", q.Unita)
+	}
+	if !u.Valida() {
+		return Quantita{}, fmt.Errorf("unità %q non valida", u)
+	}
+	if !Compatibili(q.Unita, u) {
+		return Quantita{}, fmt.Errorf("%w: non si può convertire %s (%s) in %s (%s)", ErrIncompatibili, q.Unita, q.Unita.Grandezza(), u, u.Grandezza())
+	}
+
+	valore := q.Valore * unita[q.Unita].fattore / unita[u].fattore
+	return Quantita{Valore: arrotonda(valore), Unita: u}, nil
+}
+
+// Base converte la quantità nell'unità base della sua grandezza
+func (q Quantita) Base() (Quantita, error) {
+	return q.In(q.Unita.Base())
+}
+
+// Somma restituisce q + altra nell'unità di q
+func (q Quantita) Somma(altra Quantita) (Quantita, error) {
+	convertita, err := altra.In(q.Unita)
+	if err != nil {
+		return Quantita{}, err
+	}
+	return Quantita{Valore: arrotonda(q.Valore + convertita.Valore), Unita: q.Unita}, nil
+}
+
+// Sottrai restituisce q - altra nell'unità di q
+func (q Quantita) Sottrai(altra Quantita) (Quantita, error) {
+	convertita, err := altra.In(q.Unita)
+	if err != nil {
+		return Quantita{}, err
+	}
+	return Quantita{Valore: arrotonda(q.Valore - convertita.Valore), Unita: q.Unita}, nil
+}
+
+// Leggibile esprime la quantità nell'unità più grande in cui vale almeno 1 (es. 1500 g -> 1.5 kg)
+func (q Quantita) Leggibile() Quantita {
+	base, err := q.Base()
+	if err != nil {
+		return q
+	}
+	switch base.Unita {
+	case Grammi:
+		if math.Abs(base.Valore) >= 1000 {
+			return Quantita{Valore: arrotonda(base.Valore / 1000), Unita: Chilogrammi}
+		}
+	case Millilitri:
+		if math.Abs(base.Valore) >= 1000 {
+			return Quantita{Valore: arrotonda(base.Valore / 1000), Unita: Litri}
+		}
+	}
+	return base
+}
+
+// String formatta la quantità, es. "1.5 kg"
+func (q Quantita) String() string {
+	return fmt.Sprintf("%g %s", q.Valore, q.Unita)
+}
+
+// arrotonda elimina gli errori di rappresentazione delle conversioni (es. 0.1 kg -> 100 g)
+func arrotonda(valore float64) float64 {
+	return math.Round(valore*1e6) / 1e6
+}
@@ -1,26 +1,111 @@
 package validation
 
 import (
+	"regexp"
 	"strconv"
+	"time"
 
 	"github.com/branila/restaurant-protocol/errors"
 	"github.com/branila/restaurant-protocol/models"
 )
 
+// Regexp per validare il numero di telefono del cliente
+var telefonoRegex = regexp.MustCompile(`^\+?[0-9 ]{6,}$`)
+
 // Esegue una validazione completa di un ordine
 func ValidateOrdine(ordine models.Ordine) error {
+	if err := ValidateIntestazione(ordine); err != nil {
+		return err
+	}
+
 	// Verifica che l'ordine abbia almeno una comanda
 	if len(ordine.Comande) == 0 {
 		return errors.NewOrdineVuotoError()
 	}
 
-	// Verifica che ogni comanda sia valida
+	// Verifica che ogni comanda sia valida, con un numero non ripetuto, e che i posti rientrino nei coperti
+	numeri := make(map[int]bool, len(ordine.Comande))
 	for _, comanda := range ordine.Comande {
 		if err := ValidateComanda(comanda); err != nil {
 			return err
 		}
+		if numeri[comanda.Numero] {
+			return errors.NewComandaDuplicataError(strconv.Itoa(comanda.Numero))
+		}
+		numeri[comanda.Numero] = true
+		for _, piatto := range []*models.Piatto{comanda.Primo, comanda.Secondo, comanda.Contorno} {
+			if piatto != nil && piatto.Posto != 0 {
+				if err := ValidatePosto(piatto.Posto, ordine.Coperti); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Verifica che i dati dell'intestazione siano coerenti con il tipo di ordine
+func ValidateIntestazione(ordine models.Ordine) error {
+	if ordine.Ora != "" {
+		if _, err := time.Parse("15:04", ordine.Ora); err != nil {
+			return errors.NewFormatoOraError(ordine.Ora)
+		}
+	}
+
+	tipo := ordine.TipoOrdine()
+	switch tipo {
+	case models.TipoSala:
+		if ordine.Tavolo <= 0 {
+			return errors.NewNumeroNonValidoError("tavolo", strconv.Itoa(ordine.Tavolo))
+		}
+		if ordine.Cliente != nil && ordine.Cliente.Indirizzo != "" {
+			return errors.NewDatiClienteError(tipo, "L'indirizzo è previsto solo per la consegna a domicilio")
+		}
+		return nil
+	case models.TipoAsporto:
+		if err := validateCliente(ordine); err != nil {
+			return err
+		}
+		if ordine.Cliente.Indirizzo != "" {
+			return errors.NewDatiClienteError(tipo, "L'indirizzo è previsto solo per la consegna a domicilio")
+		}
+		if ordine.Ora == "" {
+			return errors.NewDatiClienteError(tipo, "Indicare l'orario di ritiro con RITIRO HH:MM")
+		}
+		return nil
+	case models.TipoDomicilio:
+		if err := validateCliente(ordine); err != nil {
+			return err
+		}
+		if ordine.Cliente.Indirizzo == "" {
+			return errors.NewDatiClienteError(tipo, "Indicare l'indirizzo di consegna con INDIRIZZO \"...\"")
+		}
+		return nil
+	default:
+		return errors.NewDatiClienteError(tipo, "Il tipo di ordine deve essere SALA, ASPORTO o DOMICILIO")
 	}
+}
 
+// Verifica nome e telefono del cliente per gli ordini senza tavolo
+func validateCliente(ordine models.Ordine) error {
+	if ordine.Tavolo != 0 {
+		return errors.NewDatiClienteError(ordine.Tipo, "Il numero del tavolo è previsto solo per gli ordini in sala")
+	}
+	if ordine.Cliente == nil || ordine.Cliente.Nome == "" {
+		return errors.NewDatiClienteError(ordine.Tipo, "Indicare il nome del cliente con CLIENTE \"...\"")
+	}
+	if !telefonoRegex.MatchString(ordine.Cliente.Telefono) {
+		return errors.NewDatiClienteError(ordine.Tipo, "Indicare un numero di telefono valido con TELEFONO \"...\"")
+	}
+	return nil
+}
+
+// Verifica che il posto indicato su un piatto rientri nei coperti del tavolo
+func ValidatePosto(posto int, coperti int) error {
+	if posto < 1 || posto > coperti {
+		return errors.NewPostoNonValidoError(posto, coperti)
+	}
 	return nil
 }
 